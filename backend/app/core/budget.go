@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ContextBudget splits the context window of the chat model between the
+// system prompt, the retrieved CV context, a running summary of old turns and
+// the recent history, keeping room for the answer.
+type ContextBudget struct {
+	// Window is the context length of the chat model in tokens.
+	Window int
+	// Answer is kept free for the reply.
+	Answer int
+	// Summary is the most the running summary of old turns may take.
+	Summary int
+	// CVContextShare is the part of the window left after the above the
+	// retrieved context may take. The history gets the rest, including
+	// whatever the context leaves unused.
+	CVContextShare float64
+}
+
+// NewContextBudget reads the CHAT_* settings. The window defaults to
+// OLLAMA_CTX, the context length requested from Ollama.
+func NewContextBudget() ContextBudget {
+	return ContextBudget{
+		Window:         max(EnvInt("CHAT_CONTEXT_TOKENS", EnvInt("OLLAMA_CTX", 4096)), 512),
+		Answer:         max(EnvInt("CHAT_ANSWER_TOKENS", 1024), 0),
+		Summary:        max(EnvInt("CHAT_SUMMARY_TOKENS", 400), 50),
+		CVContextShare: min(max(EnvFloat("CHAT_CV_CONTEXT_SHARE", 0.5), 0), 1),
+	}
+}
+
+// free returns the tokens left for the CV context and the history next to a
+// system prompt.
+func (b ContextBudget) free(systemPrompt string) int {
+	return max(b.Window-b.Answer-b.Summary-CountTokens(systemPrompt), 0)
+}
+
+// CVContext returns the tokens the retrieved context may take next to the
+// system prompt.
+func (b ContextBudget) CVContext(systemPrompt string) int {
+	return int(float64(b.free(systemPrompt)) * b.CVContextShare)
+}
+
+// History returns the tokens left for the conversation next to the system
+// prompt, which already includes the retrieved context.
+func (b ContextBudget) History(systemPrompt string) int {
+	return b.free(systemPrompt)
+}
+
+// Tokens every message takes besides its content, for the role and the
+// separators of the chat template.
+const messageOverheadTokens = 4
+
+func messageTokens(message ChatMessage) int {
+	return CountTokens(message.Content) + messageOverheadTokens
+}
+
+func historyTokens(messages []ChatMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += messageTokens(message)
+	}
+	return tokens
+}
+
+// recentMessages returns the newest messages fitting in budget tokens. The
+// last message is always kept, even when it alone is over budget.
+func recentMessages(messages []ChatMessage, budget int) []ChatMessage {
+	start, tokens := len(messages), 0
+	for start > 0 {
+		next := messageTokens(messages[start-1])
+		if start < len(messages) && tokens+next > budget {
+			break
+		}
+		tokens += next
+		start--
+	}
+	return messages[start:]
+}
+
+// truncateTokens cuts text after the word that reaches limit tokens.
+func truncateTokens(text string, limit int) string {
+	if CountTokens(text) <= limit {
+		return text
+	}
+	words := strings.Fields(text)
+	tokens := 0
+	for i, word := range words {
+		tokens += CountTokens(word)
+		if tokens > limit {
+			return strings.Join(words[:i], " ") + " ..."
+		}
+	}
+	return text
+}
+
+const summaryPrompt = `You keep the memory of a team builder assistant. Update the summary of the conversation with the messages given.
+Keep the project requirements, the candidates suggested with the reasons, and every decision or preference of the user. Drop small talk and leave out citation numbers like [2].
+Write the summary in the language of the conversation, at most %d words. Reply with the summary only.`
+
+// Summarize folds messages into the running summary of a conversation,
+// limited to maxTokens.
+func Summarize(ctx context.Context, llm ChatProvider, summary string, messages []ChatMessage, maxTokens int) (string, error) {
+	var content strings.Builder
+	if summary != "" {
+		content.WriteString("Summary so far:\n" + summary + "\n\n")
+	}
+	content.WriteString("Messages:\n")
+	for _, message := range messages {
+		fmt.Fprintf(&content, "\n%s: %s\n", message.Role, message.Content)
+	}
+
+	reply, err := llm.StreamChat(ctx, ChatRequest{
+		Messages: []ChatMessage{
+			// Words are usually more than one token.
+			{Role: "system", Content: fmt.Sprintf(summaryPrompt, maxTokens*2/3)},
+			{Role: "user", Content: content.String()},
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return truncateTokens(reply, maxTokens), nil
+}
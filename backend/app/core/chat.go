@@ -2,18 +2,18 @@ package core
 
 import (
     "fmt"
-    "bufio"
-    "bytes"
+    "context"
     "encoding/json"
+    "errors"
     "log"
     "net/http"
     "os"
     "strconv"
     "strings"
+    "sync"
     "time"
 
     "github.com/gorilla/websocket"
-    "github.com/jackc/pgx/v5"
 )
 
 var upgrader = websocket.Upgrader{
@@ -43,29 +43,107 @@ type ChatMessage struct {
     Content string `json:"content"`
 }
 
-type OllamaRequest struct {
-    Model    string        `json:"model"`
-    Messages []ChatMessage `json:"messages"`
-    Stream   bool          `json:"stream"`
-    Options  struct {
-        NumCtx int `json:"num_ctx"`
-    } `json:"options"`
+// Every frame on the /ws socket is a JSON encoded WSMessage.
+const ChatProtocolVersion = 1
+
+const (
+    WSUserMessage    = "user_message"    // client -> server, Content is the question
+    WSToken          = "token"           // server -> client, Content is a piece of the answer
+    WSResponseDone   = "response_done"   // server -> client, the answer is complete
+    WSError          = "error"           // server -> client, Content is a user facing error
+    WSContextSources = "context_sources" // server -> client, the CV chunks used for the answer
+    WSEmployeeFilter = "employee_filter" // server -> client, Content describes the employees searched
+    WSCancel         = "cancel"          // client -> server, stop the current answer
+    WSHeartbeat      = "heartbeat"       // both ways, keeps the connection alive
+)
+
+type WSMessage struct {
+    Version   int             `json:"version"`
+    Type      string          `json:"type"`
+    Content   string          `json:"content,omitempty"`
+    Sources   []ContextSource `json:"sources,omitempty"`
+    MessageId int             `json:"message_id,omitempty"`
+    Turn      int             `json:"turn,omitempty"`      // set by the client, echoed on every reply to that message
+    Truncated bool            `json:"truncated,omitempty"` // the answer was stopped before it was complete
+}
+
+// ContextSource is a CV chunk given to the model, cited in the answer as
+// [Index].
+type ContextSource struct {
+    Index      int     `json:"index"`
+    ChunkId    int     `json:"chunk_id"`
+    EmployeeId int     `json:"employee_id"`
+    Name       string  `json:"name"`
+    Section    string  `json:"section"`
+    Score      float64 `json:"score"`
+}
+
+// chatSocket serializes writes, gorilla/websocket allows only one concurrent writer.
+type chatSocket struct {
+    ws *websocket.Conn
+    mu sync.Mutex
+}
+
+func (s *chatSocket) send(message WSMessage) error {
+    message.Version = ChatProtocolVersion
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+    return s.ws.WriteJSON(message)
 }
 
-type OllamaResponse struct {
-    Model     string `json:"model"`
-    CreatedAt string `json:"created_at"`
-    Message   struct {
-        Role    string `json:"role"`
-        Content string `json:"content"`
-    } `json:"message"`
-    Done           bool   `json:"done"`
-    Response       string `json:"response"` // For streaming
-    DoneReason     string `json:"done_reason"`
-    TotalDuration  int64  `json:"total_duration"`
+// chatSession is the state of one /ws connection.
+type chatSession struct {
+    db             DB
+    llm            ChatProvider
+    embedder       Embedder
+    retrieval      RetrievalConfig
+    rewriter       *QueryRewriter
+    budget         ContextBudget
+    socket         *chatSocket
+    conversationId int
+    conversation   []ChatMessage
+    // messageIds are the stored ids of the conversation messages, 0 for the
+    // system message and for messages that could not be saved
+    messageIds     []int
+    // summary stands in for the old messages left out of conversation
+    summary        string
+    // earlier are the CV excerpts of the last answer, still offered to the
+    // model when a follow-up question retrieves other ones
+    earlier        []ContextSource
 }
 
-func HandleChat(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user User) {
+// Excerpts of the last answer carried over to the next one at most
+const maxEarlierSources = 6
+
+// Base system prompt without context
+const baseSystemPrompt = `You are an expert team builder assistant. Help the user form effective teams based on their project requirements.
+Ask clarifying questions if needed and provide insightful suggestions.`
+
+// Appended to the system prompt when CV excerpts are given
+const citationPrompt = `
+
+When a statement about an employee relies on a CV excerpt, cite it with the excerpt's number in square brackets, e.g. [2] or [1][3]. Only cite numbers listed above.`
+
+func HandleChat(w http.ResponseWriter, r *http.Request, db DB, user User, llm ChatProvider, embedder Embedder, retrieval RetrievalConfig, rewriter *QueryRewriter, budget ContextBudget) {
+    conversationId, err := strconv.Atoi(r.URL.Query().Get("conversation"))
+    if err != nil {
+        http.Error(w, "Missing conversation", http.StatusBadRequest)
+        return
+    }
+    conversation, err := GetConversation(db, conversationId, user.Id)
+    if err != nil {
+        log.Printf("Conversation %d not available: %v", conversationId, err)
+        http.Error(w, "Conversation not found", http.StatusNotFound)
+        return
+    }
+    history, err := GetMessages(db, conversationId)
+    if err != nil {
+        log.Printf("Loading conversation %d failed: %v", conversationId, err)
+        http.Error(w, "Conversation not available", http.StatusInternalServerError)
+        return
+    }
+
     ws, err := upgrader.Upgrade(w, r, nil)
     if err != nil {
         log.Printf("WebSocket upgrade failed: %v", err)
@@ -73,12 +151,31 @@ func HandleChat(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user Use
     }
     defer ws.Close()
 
-    // Base system prompt without context
-    baseSystemPrompt := `You are an expert team builder assistant. Help the user form effective teams based on their project requirements.
-Ask clarifying questions if needed and provide insightful suggestions.`
-
-    conversation := []ChatMessage{
-        {Role: "system", Content: baseSystemPrompt},
+    session := &chatSession{
+        db:             db,
+        llm:            llm,
+        embedder:       embedder,
+        retrieval:      retrieval.With(conversation.Retrieval),
+        rewriter:       rewriter,
+        budget:         budget,
+        socket:         &chatSocket{ws: ws},
+        conversationId: conversationId,
+        conversation: []ChatMessage{
+            {Role: "system", Content: baseSystemPrompt},
+        },
+        messageIds:     []int{0},
+        summary:        conversation.Summary,
+    }
+    // Continue where the conversation was left off, the summary stands in
+    // for the messages it covers
+    for _, message := range history {
+        if message.Id > conversation.SummaryUntil {
+            session.conversation = append(session.conversation, ChatMessage{Role: message.Role, Content: message.Content})
+            session.messageIds = append(session.messageIds, message.Id)
+        }
+        if message.Role == "assistant" && len(message.Sources) > 0 {
+            session.earlier = message.Sources
+        }
     }
 
     // Ping ticker to keep connection alive
@@ -95,8 +192,7 @@ Ask clarifying questions if needed and provide insightful suggestions.`
             select {
             case <-pingTicker.C:
                 // Send ping to client
-                ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-                if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+                if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
                     log.Printf("WebSocket ping error: %v", err)
                     return
                 }
@@ -106,6 +202,17 @@ Ask clarifying questions if needed and provide insightful suggestions.`
         }
     }()
 
+    // Answers are generated in the background, so the socket keeps reading
+    // and a cancel message can stop the answer in progress. Only one answer
+    // runs at a time, which also keeps the conversation single-user.
+    cancelResponse := context.CancelFunc(func() {})
+    responseDone := make(chan struct{})
+    close(responseDone)
+    defer func() {
+        cancelResponse()
+        <-responseDone
+    }()
+
     for {
         _, message, err := ws.ReadMessage()
         if err != nil {
@@ -115,122 +222,367 @@ Ask clarifying questions if needed and provide insightful suggestions.`
             break
         }
 
-        // Handle pong from client
-        if string(message) == "pong" {
+        var incoming WSMessage
+        if err := json.Unmarshal(message, &incoming); err != nil {
+            session.socket.send(WSMessage{Type: WSError, Content: "Malformed message."})
             continue
         }
 
-        // Add user message to conversation
-        userMsg := string(message)
-        conversation = append(conversation, ChatMessage{Role: "user", Content: userMsg})
-
-        // Get context from CV chunks
-        cvContext := ""
-        queryEmbedding, err := GetEmbedding(userMsg)
-        if err == nil {
-            chunks, err := GetRelevantCVChunks(conn, queryEmbedding, 3) // Get top 3 chunks
-            if err == nil && len(chunks) > 0 {
-                cvContext = "\n\nRelevant CV context:\n"
-                for i, chunk := range chunks {
-                    cvContext += fmt.Sprintf("- Context %d: %s\n", i+1, chunk)
-                }
-                fmt.Println(cvContext)
-            } else if err != nil {
-                log.Printf("Error getting CV context: %v", err)
-            }
-        } else {
-            log.Printf("Error getting embedding: %v", err)
+        if incoming.Version != ChatProtocolVersion {
+            session.socket.send(WSMessage{Type: WSError, Content: fmt.Sprintf("Unsupported protocol version %d, please reload the page.", incoming.Version)})
+            continue
         }
 
-        // Update system prompt with context
-        systemPrompt := baseSystemPrompt + cvContext
+        switch incoming.Type {
+        case WSHeartbeat:
+            session.socket.send(WSMessage{Type: WSHeartbeat})
+            continue
+        case WSCancel:
+            cancelResponse()
+            continue
+        case WSUserMessage:
+        default:
+            session.socket.send(WSMessage{Type: WSError, Content: fmt.Sprintf("Unknown message type %q.", incoming.Type)})
+            continue
+        }
 
-        // Update the system message in the conversation
-        if len(conversation) > 0 && conversation[0].Role == "system" {
-            conversation[0].Content = systemPrompt
+        userMsg := strings.TrimSpace(incoming.Content)
+        if userMsg == "" {
+            continue
         }
 
-        // Call Ollama API with streaming
-        ollamaReq := OllamaRequest{
-            Model:	os.Getenv("OLLAMA_MODEL"),
-            Messages: conversation,
-            Stream:   true,
+        // A new question stops the answer still in progress
+        cancelResponse()
+        <-responseDone
+
+        ctx, cancel := context.WithCancel(context.Background())
+        cancelResponse = cancel
+        responseDone = make(chan struct{})
+        go func(turn int, done chan struct{}) {
+            defer close(done)
+            defer cancel()
+            session.answer(ctx, turn, userMsg)
+        }(incoming.Turn, responseDone)
+    }
+}
+
+// answer runs one turn: store the question, retrieve CV context and stream the
+// model's answer. A cancelled answer is kept as truncated.
+func (s *chatSession) answer(ctx context.Context, turn int, userMsg string) {
+    // Add user message to conversation
+    s.conversation = append(s.conversation, ChatMessage{Role: "user", Content: userMsg})
+    userMsgId, err := AddMessage(s.db, Message{ConversationId: s.conversationId, Role: "user", Content: userMsg})
+    if err != nil {
+        log.Printf("Error saving user message: %v", err)
+        userMsgId = 0
+    }
+    s.messageIds = append(s.messageIds, userMsgId)
+
+    // A follow-up question is searched for together with the requirements
+    // stated earlier in the conversation
+    query := userMsg
+    if s.rewriter != nil {
+        query = s.rewriter.Rewrite(ctx, s.summary, s.conversation[1:len(s.conversation)-1], userMsg)
+        if query != userMsg {
+            log.Printf("Searching for %q", query)
         }
+    }
 
-        numCTX, err := strconv.Atoi(os.Getenv("OLLAMA_CTX"))
-        if err != nil {
-            log.Println("Could not convert the OLLAMA_CTX env variable to int.")
-            numCTX = 4096
-        }
-        ollamaReq.Options.NumCtx = numCTX // Set context length
-
-        // Make streaming request to Ollama
-        reqBody, _ := json.Marshal(ollamaReq)
-        ollamaAPI := os.Getenv("OLLAMA_API")
-        if ollamaAPI == "" {
-            ollamaAPI = "http://localhost:11434/api/chat"
-        }
-        resp, err := http.Post(
-            ollamaAPI,
-            "application/json", 
-            bytes.NewReader(reqBody),
-        )
-        if err != nil {
-            log.Printf("Ollama API error: %v", err)
-            ws.WriteMessage(websocket.TextMessage, []byte("Sorry, I'm having trouble connecting to the assistant."))
-            continue
+    // Departments, locations and seniority levels named in the question
+    // restrict the employees searched
+    retrieval := s.retrieval
+    filter, err := ParseEmployeeFilter(s.db, query, retrieval.Filter)
+    if err != nil {
+        log.Printf("Error parsing employee filter: %v", err)
+    }
+    retrieval.Filter = filter
+
+    // Skills named in the question are looked up in the taxonomy, so
+    // "k8s" also finds employees who know Kubernetes or Amazon EKS
+    skillContext := ""
+    searchText := query
+    requirements, err := MatchSkillRequirements(s.db, query, retrieval.Filter)
+    if err != nil {
+        log.Printf("Error matching skill requirements: %v", err)
+    }
+    if len(requirements) > 0 {
+        skillContext = "\n\nEmployees with the requested skills in their profile:\n"
+        for _, requirement := range requirements {
+            skillContext += formatSkillRequirement(requirement)
+            searchText += "\n" + strings.Join(requirement.Terms(), ", ")
         }
-        defer resp.Body.Close()
+    }
 
-        // Create a buffer to accumulate the assistant's response
-        var assistantResponseBuilder strings.Builder
+    // The user sees the restriction, a misread question would otherwise
+    // silently leave out candidates
+    filterContext := ""
+    if description := retrieval.Filter.Describe(); description != "" {
+        filterContext = "\n\nOnly employees matching: " + description + "."
+        s.socket.send(WSMessage{Type: WSEmployeeFilter, Turn: turn, Content: description})
+    }
 
-        // Stream the response line by line
-        scanner := bufio.NewScanner(resp.Body)
-        for scanner.Scan() {
-            line := scanner.Bytes()
-            var chunk OllamaResponse
-            if err := json.Unmarshal(line, &chunk); err != nil {
-                log.Printf("Error parsing Ollama response: %v", err)
-                continue
-            }
+    // Get context from CV chunks, as many excerpts as the context budget
+    // leaves room for
+    excerptBudget := s.budget.CVContext(baseSystemPrompt+citationPrompt) - CountTokens(filterContext+skillContext)
+    cvContext, sources := s.retrieveCVContext(searchText, retrieval, excerptBudget)
+    cvContext, sources = s.addEarlierExcerpts(cvContext, sources, retrieval.Filter, excerptBudget)
+    s.earlier = sources
+    if len(sources) > 0 {
+        // Only ids, the excerpts hold personal data
+        var chunkIds []int
+        for _, source := range sources {
+            chunkIds = append(chunkIds, source.ChunkId)
+        }
+        log.Printf("Conversation %d: CV context from chunks %v", s.conversationId, chunkIds)
+    }
 
-            // Skip empty responses
-            if chunk.Response == "" && chunk.Message.Content == "" {
-                continue
-            }
+    cvContext = skillContext + cvContext
+    if cvContext != "" {
+        cvContext = filterContext + cvContext
+    }
 
-            // Get content from either field
-            content := chunk.Response
-            if content == "" {
-                content = chunk.Message.Content
-            }
+    if len(sources) > 0 {
+        cvContext += citationPrompt
+        s.socket.send(WSMessage{Type: WSContextSources, Turn: turn, Sources: sources})
+    }
 
-            // Add to buffer
-            assistantResponseBuilder.WriteString(content)
+    // The history gets what the context leaves of the window, old turns that
+    // do not fit are folded into the summary
+    historyBudget := s.budget.History(baseSystemPrompt + cvContext)
+    s.fitHistory(ctx, historyBudget)
 
-            // Send chunk to client
-            ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-            if err := ws.WriteMessage(websocket.TextMessage, []byte(content)); err != nil {
-                log.Printf("WebSocket write error: %v", err)
-                break
-            }
+    // Update the system message in the conversation with the context
+    if len(s.conversation) > 0 && s.conversation[0].Role == "system" {
+        s.conversation[0].Content = baseSystemPrompt + summaryContext(s.summary) + cvContext
+    }
 
-            // Break if this is the last chunk
-            if chunk.Done {
-                break
-            }
-        }
+    // Without a summary the oldest messages are left out for this answer
+    messages := append([]ChatMessage{s.conversation[0]}, recentMessages(s.conversation[1:], historyBudget)...)
 
-        if err := scanner.Err(); err != nil {
-            log.Printf("Error reading Ollama response: %v", err)
+    // Stream the assistant's response to the client as it is generated
+    fullResponse, err := s.llm.StreamChat(ctx, ChatRequest{Messages: messages}, func(content string) error {
+        return s.socket.send(WSMessage{Type: WSToken, Turn: turn, Content: content})
+    })
+    truncated := errors.Is(err, context.Canceled)
+    if err != nil && !truncated {
+        log.Printf("Chat provider error: %v", err)
+        s.socket.send(WSMessage{Type: WSError, Turn: turn, Content: "Sorry, I'm having trouble connecting to the assistant."})
+        if fullResponse == "" {
+            return
         }
+    }
 
+    messageId := 0
+    if fullResponse != "" {
         // Add full assistant response to conversation
-        fullResponse := assistantResponseBuilder.String()
-        conversation = append(conversation, ChatMessage{
+        s.conversation = append(s.conversation, ChatMessage{
             Role:	"assistant",
             Content: fullResponse,
         })
+        messageId, err = AddMessage(s.db, Message{ConversationId: s.conversationId, Role: "assistant", Content: fullResponse, CVContext: cvContext, Sources: sources, Truncated: truncated})
+        if err != nil {
+            log.Printf("Error saving assistant message: %v", err)
+            messageId = 0
+        }
+        s.messageIds = append(s.messageIds, messageId)
+    }
+
+    s.socket.send(WSMessage{Type: WSResponseDone, Turn: turn, MessageId: messageId, Truncated: truncated})
+}
+
+// fitHistory folds the oldest messages into the running summary when the
+// history is over budget. It folds down to half the budget, so the summary is
+// not rewritten on every turn. The question being answered always stays.
+func (s *chatSession) fitHistory(ctx context.Context, budget int) {
+    history := s.conversation[1:]
+    if historyTokens(history) <= budget {
+        return
+    }
+    fold := len(history) - len(recentMessages(history, budget/2))
+    if fold == 0 {
+        return
+    }
+
+    summary, err := Summarize(ctx, s.llm, s.summary, history[:fold], s.budget.Summary)
+    if err != nil {
+        log.Printf("Summarizing conversation %d failed: %v", s.conversationId, err)
+        return
+    }
+
+    // Ids grow with every message, the last saved one marks the end of the
+    // summary
+    until := 0
+    for _, id := range s.messageIds[1 : fold+1] {
+        until = max(until, id)
+    }
+    if until > 0 {
+        if err := SetConversationSummary(s.db, s.conversationId, summary, until); err != nil {
+            log.Printf("Error saving conversation summary: %v", err)
+        }
+    }
+
+    s.summary = summary
+    s.conversation = append(s.conversation[:1], history[fold:]...)
+    s.messageIds = append(s.messageIds[:1], s.messageIds[fold+1:]...)
+}
+
+func summaryContext(summary string) string {
+    if summary == "" {
+        return ""
+    }
+    return "\n\nSummary of the earlier conversation:\n" + summary
+}
+
+// excerpts collects the numbered CV excerpts given to the model, skipping
+// those that would take the text over budget tokens.
+type excerpts struct {
+    text    string
+    sources []ContextSource
+    budget  int
+}
+
+// add appends the chunk as excerpt [n], after prefix when given, and reports
+// whether it fit. Chunks usually start with a header naming the employee and
+// section, those stored before the header existed get the employee's name.
+func (e *excerpts) add(prefix string, chunk CVChunk, score float64) bool {
+    text := chunk.Chunk
+    if !strings.HasPrefix(text, "Employee: ") {
+        text = "Employee: " + chunk.Name + "\n" + text
+    }
+    excerpt := prefix + fmt.Sprintf("[%d] %s\n", len(e.sources)+1, text)
+    if CountTokens(e.text+excerpt) > e.budget {
+        return false
+    }
+    e.text += excerpt
+    e.sources = append(e.sources, ContextSource{
+        Index:      len(e.sources) + 1,
+        ChunkId:    chunk.Id,
+        EmployeeId: chunk.UserId,
+        Name:       chunk.Name,
+        Section:    chunk.Section,
+        Score:      score,
+    })
+    return true
+}
+
+// retrieveCVContext finds the CV chunks relevant to the question, either the
+// best ones overall or grouped by the best matching employees, within budget
+// tokens.
+func (s *chatSession) retrieveCVContext(searchText string, retrieval RetrievalConfig, budget int) (string, []ContextSource) {
+    queryEmbedding, err := GetEmbedding(s.embedder, searchText)
+    if err != nil {
+        log.Printf("Error getting embedding: %v", err)
+        return "", nil
+    }
+
+    result := &excerpts{budget: budget}
+    if retrieval.Mode == RetrievalEmployees {
+        employees, err := GetRelevantEmployees(s.db, queryEmbedding, searchText, retrieval)
+        if err != nil {
+            log.Printf("Error getting CV context: %v", err)
+            return "", nil
+        }
+        header := "\n\nCandidates from the CVs, best match first:\n"
+        candidates := 0
+        for _, employee := range employees {
+            // The candidate is only listed with an excerpt that fits
+            prefix := fmt.Sprintf("- Candidate %d: %s\n", candidates+1, employee.Name)
+            if candidates == 0 {
+                prefix = header + prefix
+            }
+            for _, chunk := range employee.Chunks {
+                if result.add(prefix, chunk, 1-chunk.Distance) {
+                    prefix = ""
+                }
+            }
+            if prefix == "" {
+                candidates++
+            }
+        }
+        return result.text, result.sources
+    }
+
+    chunks, err := SelectCVChunks(s.db, queryEmbedding, searchText, retrieval)
+    if err != nil {
+        log.Printf("Error getting CV context: %v", err)
+        return "", nil
+    }
+    prefix := "\n\nRelevant CV excerpts:\n"
+    for _, chunk := range chunks {
+        if result.add(prefix, chunk, 1-chunk.Distance) {
+            prefix = ""
+        }
+    }
+    return result.text, result.sources
+}
+
+// addEarlierExcerpts appends the excerpts of the last answer the new search
+// did not find again, so the candidates discussed so far stay comparable.
+func (s *chatSession) addEarlierExcerpts(cvContext string, sources []ContextSource, filter EmployeeFilter, budget int) (string, []ContextSource) {
+    found := map[int]bool{}
+    for _, source := range sources {
+        found[source.ChunkId] = true
+    }
+    var ids []int
+    scores := map[int]float64{}
+    for _, source := range s.earlier {
+        if !found[source.ChunkId] && len(ids) < maxEarlierSources {
+            ids = append(ids, source.ChunkId)
+            scores[source.ChunkId] = source.Score
+        }
+    }
+    if len(ids) == 0 {
+        return cvContext, sources
+    }
+
+    chunks, err := GetCVChunks(s.db, ids, filter)
+    if err != nil {
+        log.Printf("Error loading earlier CV excerpts: %v", err)
+        return cvContext, sources
+    }
+
+    result := &excerpts{text: cvContext, sources: sources, budget: budget}
+    prefix := "\n\nCandidates discussed earlier in the conversation:\n"
+    for _, chunk := range chunks {
+        if result.add(prefix, chunk, scores[chunk.Id]) {
+            prefix = ""
+        }
+    }
+    return result.text, result.sources
+}
+
+// formatSkillRequirement lists the employees having a requested skill, with
+// the related skill they actually have when it is not the requested one.
+func formatSkillRequirement(requirement SkillRequirement) string {
+    line := "- " + requirement.Skill.Name
+    if len(requirement.Related) > 0 {
+        var related []string
+        for _, skill := range requirement.Related {
+            related = append(related, skill.Name)
+        }
+        line += " (including " + strings.Join(related, ", ") + ")"
+    }
+    if len(requirement.Employees) == 0 {
+        return line + ": no employee lists this skill\n"
+    }
+
+    var employees []string
+    for _, employee := range requirement.Employees {
+        var details []string
+        if employee.Skill != requirement.Skill.Name {
+            details = append(details, employee.Skill)
+        }
+        if employee.YearsExperience != nil {
+            details = append(details, strconv.FormatFloat(*employee.YearsExperience, 'f', -1, 64)+" years")
+        }
+        if employee.LastUsedYear != nil {
+            details = append(details, "last used "+strconv.Itoa(*employee.LastUsedYear))
+        }
+        if len(details) > 0 {
+            employees = append(employees, employee.Name+" ("+strings.Join(details, ", ")+")")
+        } else {
+            employees = append(employees, employee.Name)
+        }
     }
+    return line + ": " + strings.Join(employees, "; ") + "\n"
 }
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const DefaultConversationTitle = "New conversation"
+
+type Conversation struct {
+	Id        int
+	UserId    int
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Retrieval RetrievalOverride
+	// Summary covers the messages up to SummaryUntil, which the chat no
+	// longer gives the model.
+	Summary      string
+	SummaryUntil int
+}
+
+type Message struct {
+	Id             int
+	ConversationId int
+	Role           string
+	Content        string
+	CVContext      string
+	Sources        []ContextSource
+	Truncated      bool
+	CreatedAt      time.Time
+}
+
+// GetConversation returns the conversation only if it belongs to the user.
+func GetConversation(db DB, conversationId int, userId int) (Conversation, error) {
+	var conversation Conversation
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT id, user_id, title, created_at, updated_at, retrieval_top_k, retrieval_min_similarity, retrieval_mmr_lambda,
+		summary, summary_until
+		FROM conversations WHERE id = $1 AND user_id = $2`,
+		conversationId, userId).Scan(
+		&conversation.Id, &conversation.UserId, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt,
+		&conversation.Retrieval.TopK, &conversation.Retrieval.MinSimilarity, &conversation.Retrieval.MMRLambda,
+		&conversation.Summary, &conversation.SummaryUntil)
+	if err != nil {
+		return conversation, err
+	}
+	return conversation, nil
+}
+
+func GetMessages(db DB, conversationId int) ([]Message, error) {
+	rows, err := db.Query(
+		context.Background(),
+		"SELECT id, conversation_id, role, content, cv_context, sources, truncated, created_at FROM messages WHERE conversation_id = $1 ORDER BY id",
+		conversationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		if err := rows.Scan(&message.Id, &message.ConversationId, &message.Role, &message.Content, &message.CVContext, &message.Sources, &message.Truncated, &message.CreatedAt); err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// SetConversationSummary stores the running summary of the messages up to
+// and including the message until.
+func SetConversationSummary(db DB, conversationId int, summary string, until int) error {
+	_, err := db.Exec(
+		context.Background(),
+		"UPDATE conversations SET summary = $1, summary_until = $2 WHERE id = $3",
+		summary, until, conversationId)
+	return err
+}
+
+// AddMessage stores a turn of the conversation. The first user message also
+// becomes the title of a conversation that has not been renamed yet.
+func AddMessage(db DB, message Message) (int, error) {
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return -1, err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	// A nil slice would be stored as JSON null.
+	sources := message.Sources
+	if sources == nil {
+		sources = []ContextSource{}
+	}
+
+	var messageId int
+	err = tx.QueryRow(
+		context.Background(),
+		"INSERT INTO messages (conversation_id, role, content, cv_context, sources, truncated) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		message.ConversationId, message.Role, message.Content, message.CVContext, sources, message.Truncated).Scan(&messageId)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE conversations SET updated_at = now() WHERE id = $1", message.ConversationId)
+	if err != nil {
+		return -1, err
+	}
+
+	if message.Role == "user" {
+		_, err = tx.Exec(
+			context.Background(),
+			"UPDATE conversations SET title = $1 WHERE id = $2 AND title = $3",
+			conversationTitle(message.Content), message.ConversationId, DefaultConversationTitle)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	err = tx.Commit(context.Background())
+	if err != nil {
+		return -1, err
+	}
+
+	return messageId, nil
+}
+
+func conversationTitle(firstMessage string) string {
+	title := strings.Join(strings.Fields(firstMessage), " ")
+	if runes := []rune(title); len(runes) > 60 {
+		title = string(runes[:60]) + "..."
+	}
+	if title == "" {
+		return DefaultConversationTitle
+	}
+	return title
+}
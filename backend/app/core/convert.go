@@ -0,0 +1,382 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrUnsupportedCVFormat = errors.New("unsupported CV file format")
+
+// CVConverter turns one file format into the markdown stored in users.cv.
+// Sniff looks at the magic bytes, the extensions are only used to pick
+// between text based formats, which cannot be told apart by their contents.
+type CVConverter struct {
+	Format     string
+	Extensions []string
+	Sniff      func(data []byte) bool
+	Convert    func(data []byte) (string, error)
+	TextBased  bool
+}
+
+// cvConverters are tried in order, binary formats first.
+var cvConverters = []CVConverter{
+	{Format: "pdf", Extensions: []string{".pdf"}, Sniff: isPDF, Convert: PDFToMarkDown},
+	{Format: "docx", Extensions: []string{".docx"}, Sniff: isDOCX, Convert: DocxToMarkDown},
+	{Format: "odt", Extensions: []string{".odt"}, Sniff: isODT, Convert: ODTToMarkDown},
+	{Format: "html", Extensions: []string{".html", ".htm"}, Sniff: isHTML, Convert: HTMLToMarkDown, TextBased: true},
+	{Format: "markdown", Extensions: []string{".md", ".markdown"}, Sniff: looksLikeMarkdown, Convert: NormalizeMarkdown, TextBased: true},
+	{Format: "text", Extensions: []string{".txt"}, Sniff: isText, Convert: TextToMarkDown, TextBased: true},
+}
+
+// CVFileExtensions lists every extension the upload form accepts.
+func CVFileExtensions() []string {
+	var extensions []string
+	for _, converter := range cvConverters {
+		extensions = append(extensions, converter.Extensions...)
+	}
+	return extensions
+}
+
+// ConvertCV turns an uploaded CV into markdown. The converter is picked from
+// the file's contents, not from the browser's Content-Type.
+func ConvertCV(data []byte, fileName string) (string, error) {
+	converter, ok := DetectCVFormat(data, fileName)
+	if !ok {
+		return "", ErrUnsupportedCVFormat
+	}
+	return converter.Convert(data)
+}
+
+// DetectCVFormat sniffs the magic bytes of a file. Any text file can pass for
+// HTML, markdown or plain text, so for those the extension decides when it
+// names a text based format.
+func DetectCVFormat(data []byte, fileName string) (CVConverter, bool) {
+	for _, converter := range cvConverters {
+		if !converter.Sniff(data) {
+			continue
+		}
+		if converter.TextBased {
+			if byExtension, ok := textConverterByExtension(fileName); ok {
+				return byExtension, true
+			}
+		}
+		return converter, true
+	}
+	return CVConverter{}, false
+}
+
+func textConverterByExtension(fileName string) (CVConverter, bool) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, converter := range cvConverters {
+		if !converter.TextBased {
+			continue
+		}
+		for _, extension := range converter.Extensions {
+			if ext == extension {
+				return converter, true
+			}
+		}
+	}
+	return CVConverter{}, false
+}
+
+func isPDF(data []byte) bool {
+	// Only a BOM or whitespace may come before the header, a text CV may
+	// mention "%PDF-" further down.
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-"))
+}
+
+func isDOCX(data []byte) bool {
+	return zipFile(data, "word/document.xml") != nil
+}
+
+func isODT(data []byte) bool {
+	// ODF packages start with an uncompressed "mimetype" entry.
+	mimetype := zipFile(data, "mimetype")
+	if mimetype == nil {
+		return false
+	}
+	contents, err := readZipFile(mimetype)
+	return err == nil && strings.TrimSpace(string(contents)) == "application/vnd.oasis.opendocument.text"
+}
+
+func isHTML(data []byte) bool {
+	return isText(data) && strings.HasPrefix(http.DetectContentType(data), "text/html")
+}
+
+func isText(data []byte) bool {
+	text, ok := decodeText(data)
+	if !ok {
+		return false
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' && r != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+var markdownSyntax = regexp.MustCompile(`(?m)^(#{1,6} \S|\*\*\S|[-*] \S|\d+\. \S|[=-]{3,}\s*$)`)
+
+func looksLikeMarkdown(data []byte) bool {
+	if !isText(data) {
+		return false
+	}
+	text, _ := decodeText(data)
+	return len(markdownSyntax.FindAllStringIndex(text, 3)) >= 3
+}
+
+func zipFile(data []byte, name string) *zip.File {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return nil
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+	for _, file := range archive.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// decodeText returns the text of a UTF-8 or UTF-16 (with BOM) file, which is
+// what editors on Windows write when saving as plain text.
+func decodeText(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		littleEndian := data[0] == 0xFF
+		data = data[2:]
+		if len(data)%2 != 0 {
+			return "", false
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if littleEndian {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), true
+	}
+
+	if !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.ReplaceAll(text, "\f", "\n")
+}
+
+// NormalizeMarkdown only cleans up encoding and line endings of a markdown CV.
+func NormalizeMarkdown(data []byte) (string, error) {
+	text, ok := decodeText(data)
+	if !ok {
+		return "", errors.New("the file is not valid UTF-8 or UTF-16 text")
+	}
+
+	lines := strings.Split(normalizeNewlines(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+
+	markdown := strings.TrimSpace(strings.Join(lines, "\n"))
+	if markdown == "" {
+		return "", errors.New("the file is empty")
+	}
+	return markdown, nil
+}
+
+// TextToMarkDown turns a plain text CV into markdown. Short all-caps lines and
+// underlined lines become headings and bullet glyphs become list items.
+func TextToMarkDown(data []byte) (string, error) {
+	text, ok := decodeText(data)
+	if !ok {
+		return "", errors.New("the file is not valid UTF-8 or UTF-16 text")
+	}
+
+	lines := strings.Split(normalizeNewlines(text), "\n")
+	var writer markdownWriter
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			writer.endBlock()
+		case i+1 < len(lines) && isUnderline(lines[i+1], '='):
+			writer.heading(1, line)
+			i++
+		case i+1 < len(lines) && isUnderline(lines[i+1], '-'):
+			writer.heading(2, line)
+			i++
+		default:
+			if item, ok := bulletItem(line); ok {
+				writer.startListItem(0)
+				writer.text(strings.TrimPrefix(item, "- "))
+				writer.endBlock()
+			} else if len(strings.Fields(line)) <= 8 && isUpperCase(line) {
+				writer.heading(2, line)
+			} else {
+				// Hard wrapped lines of a paragraph stay on their own line.
+				writer.text(line)
+				writer.lineBreak()
+			}
+		}
+	}
+
+	markdown := writer.String()
+	if markdown == "" {
+		return "", errors.New("the file is empty")
+	}
+	return markdown, nil
+}
+
+func isUnderline(line string, char rune) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 3 && strings.Trim(line, string(char)) == ""
+}
+
+// bulletItem turns a line starting with a bullet glyph into a markdown list item.
+func bulletItem(text string) (string, bool) {
+	for _, bullet := range []string{"•", "●", "▪", "■", "◦", "○", "", "– ", "- ", "* "} {
+		if strings.HasPrefix(text, bullet) {
+			return "- " + strings.TrimSpace(strings.TrimPrefix(text, bullet)), true
+		}
+	}
+	return "", false
+}
+
+func isUpperCase(text string) bool {
+	hasLetter := false
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+			if !unicode.IsUpper(r) {
+				return false
+			}
+		}
+	}
+	return hasLetter
+}
+
+// markdownWriter collects the blocks of a converted document. Inline text is
+// appended with collapsed whitespace until the current block ends.
+type markdownWriter struct {
+	blocks       []markdownBlock
+	current      strings.Builder
+	prefix       string
+	pendingSpace bool
+}
+
+type markdownBlock struct {
+	text     string
+	listItem bool
+}
+
+// text appends inline text to the current block.
+func (w *markdownWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	if unicode.IsSpace([]rune(s)[0]) {
+		w.pendingSpace = true
+	}
+	for _, word := range strings.FieldsFunc(s, unicode.IsSpace) {
+		current := w.current.String()
+		if w.pendingSpace && current != "" && !strings.HasSuffix(current, "\n") {
+			w.current.WriteString(" ")
+		}
+		w.current.WriteString(word)
+		w.pendingSpace = true
+	}
+	w.pendingSpace = unicode.IsSpace([]rune(s)[len([]rune(s))-1])
+}
+
+// lineBreak starts a new line without ending the block.
+func (w *markdownWriter) lineBreak() {
+	if w.current.Len() > 0 && !strings.HasSuffix(w.current.String(), "\n") {
+		w.current.WriteString("\n")
+	}
+	w.pendingSpace = false
+}
+
+// startBlock ends the current block and starts one with the given markdown
+// prefix. A paragraph opening right at the start of a list item continues
+// the item instead.
+func (w *markdownWriter) startBlock(prefix string) {
+	if prefix == "" && w.prefix != "" && w.current.Len() == 0 {
+		return
+	}
+	w.endBlock()
+	w.prefix = prefix
+}
+
+func (w *markdownWriter) heading(level int, s string) {
+	w.startBlock(strings.Repeat("#", min(max(level, 1), 6)) + " ")
+	w.text(s)
+	w.endBlock()
+}
+
+func (w *markdownWriter) startListItem(depth int) {
+	w.startBlock(strings.Repeat("  ", depth) + "- ")
+}
+
+func (w *markdownWriter) endBlock() {
+	content := strings.TrimSpace(w.current.String())
+	if content != "" {
+		w.blocks = append(w.blocks, markdownBlock{
+			text:     w.prefix + content,
+			listItem: strings.HasPrefix(strings.TrimLeft(w.prefix, " "), "- "),
+		})
+	}
+	w.current.Reset()
+	w.prefix = ""
+	w.pendingSpace = false
+}
+
+// String returns the markdown, consecutive list items form one list and all
+// other blocks are separated by a blank line.
+func (w *markdownWriter) String() string {
+	w.endBlock()
+
+	var markdown strings.Builder
+	for i, block := range w.blocks {
+		if i > 0 {
+			if block.listItem && w.blocks[i-1].listItem {
+				markdown.WriteString("\n")
+			} else {
+				markdown.WriteString("\n\n")
+			}
+		}
+		markdown.WriteString(block.text)
+	}
+	return markdown.String()
+}
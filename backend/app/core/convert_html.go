@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToMarkDown converts an HTML page, e.g. a CV exported from a wiki.
+// Scripts, styles and page chrome are dropped, headings, lists and tables
+// keep their structure.
+func HTMLToMarkDown(htmlBytes []byte) (string, error) {
+	text, ok := decodeText(htmlBytes)
+	if !ok {
+		return "", errors.New("the HTML file is not valid UTF-8 or UTF-16 text")
+	}
+
+	document, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var writer markdownWriter
+	htmlNodeToMarkdown(&writer, document, 0)
+
+	markdown := writer.String()
+	if markdown == "" {
+		return "", errors.New("the HTML file contains no text")
+	}
+	return markdown, nil
+}
+
+func htmlNodeToMarkdown(writer *markdownWriter, node *html.Node, listDepth int) {
+	switch node.Type {
+	case html.TextNode:
+		writer.text(node.Data)
+		return
+	case html.ElementNode:
+	default:
+		htmlChildrenToMarkdown(writer, node, listDepth)
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Nav, atom.Button, atom.Form:
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(node.Data[1] - '0')
+		writer.startBlock(strings.Repeat("#", level) + " ")
+		htmlChildrenToMarkdown(writer, node, listDepth)
+		writer.endBlock()
+	case atom.Ul, atom.Ol:
+		writer.endBlock()
+		htmlChildrenToMarkdown(writer, node, listDepth+1)
+		writer.endBlock()
+	case atom.Li:
+		writer.startListItem(max(listDepth-1, 0))
+		htmlChildrenToMarkdown(writer, node, listDepth)
+		writer.endBlock()
+	case atom.Tr:
+		// A table row becomes one line with its cells separated by "|".
+		writer.startBlock("")
+		first := true
+		for cell := node.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+				continue
+			}
+			if !first {
+				writer.text(" | ")
+			}
+			writer.text(" " + htmlInlineText(cell) + " ")
+			first = false
+		}
+		writer.endBlock()
+	case atom.Br:
+		writer.lineBreak()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer,
+		atom.Aside, atom.Blockquote, atom.Pre, atom.Dl, atom.Dt, atom.Dd, atom.Table, atom.Hr:
+		writer.startBlock("")
+		htmlChildrenToMarkdown(writer, node, listDepth)
+		writer.endBlock()
+	default:
+		htmlChildrenToMarkdown(writer, node, listDepth)
+	}
+}
+
+func htmlChildrenToMarkdown(writer *markdownWriter, node *html.Node, listDepth int) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		htmlNodeToMarkdown(writer, child, listDepth)
+	}
+}
+
+// htmlInlineText is the text of a node with collapsed whitespace.
+func htmlInlineText(node *html.Node) string {
+	var text bytes.Buffer
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteString(" ")
+		}
+		if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const odtTextNamespace = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+// ODTToMarkDown converts the body of an OpenDocument text file (LibreOffice,
+// Google Docs export). Headings keep their outline level and lists their
+// nesting.
+func ODTToMarkDown(odtBytes []byte) (string, error) {
+	content := zipFile(odtBytes, "content.xml")
+	if content == nil {
+		return "", errors.New("the ODT file has no content.xml")
+	}
+	contentXML, err := readZipFile(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to read content.xml: %w", err)
+	}
+
+	var writer markdownWriter
+	listDepth := 0
+	decoder := xml.NewDecoder(bytes.NewReader(contentXML))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			if element.Name.Space != odtTextNamespace {
+				continue
+			}
+			switch element.Name.Local {
+			case "h":
+				level := 1
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "outline-level" {
+						if n, err := strconv.Atoi(attr.Value); err == nil {
+							level = n
+						}
+					}
+				}
+				writer.startBlock(strings.Repeat("#", min(max(level, 1), 6)) + " ")
+			case "p":
+				writer.startBlock("")
+			case "list":
+				listDepth++
+			case "list-item":
+				writer.startListItem(max(listDepth-1, 0))
+			case "s":
+				writer.text(" ")
+			case "tab":
+				writer.text(" ")
+			case "line-break":
+				writer.lineBreak()
+			case "note", "tracked-changes":
+				// Footnotes and change tracking are not part of the text.
+				if err := decoder.Skip(); err != nil {
+					return "", fmt.Errorf("failed to parse content.xml: %w", err)
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Space != odtTextNamespace {
+				continue
+			}
+			switch element.Name.Local {
+			case "h", "p", "list-item":
+				writer.endBlock()
+			case "list":
+				listDepth--
+			}
+		case xml.CharData:
+			writer.text(string(element))
+		}
+	}
+
+	markdown := writer.String()
+	if markdown == "" {
+		return "", errors.New("the ODT file contains no text")
+	}
+	return markdown, nil
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfLine is a line of text drawn on a PDF page.
+type pdfLine struct {
+	text     string
+	y        float64
+	fontSize float64
+	bold     bool
+}
+
+// PDFToMarkDown extracts the text of a PDF. Lines set in a noticeably larger
+// or bold all-caps font become headings and bullet glyphs become list items,
+// so chunkCV sees the same structure as for a converted DOCX.
+func PDFToMarkDown(pdfBytes []byte) (result string, err error) {
+	// The pdf package panics on some malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(pdfBytes), int64(len(pdfBytes)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	var pages [][]pdfLine
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		lines, err := pdfPageLines(page)
+		if err != nil {
+			return pdfPlainText(reader)
+		}
+		pages = append(pages, lines)
+	}
+
+	bodySize := pdfBodyFontSize(pages)
+	var markdown strings.Builder
+	for _, lines := range pages {
+		for i, line := range lines {
+			// A larger vertical gap than usual starts a new paragraph.
+			if i > 0 {
+				gap := lines[i-1].y - line.y
+				if gap > 1.8*math.Max(line.fontSize, bodySize) {
+					markdown.WriteString("\n")
+				}
+			}
+			markdown.WriteString(pdfLineToMarkdown(line, bodySize))
+			markdown.WriteString("\n")
+		}
+		markdown.WriteString("\n")
+	}
+
+	result = strings.TrimSpace(markdown.String())
+	if result == "" {
+		return "", errors.New("the PDF contains no extractable text, it may be a scanned image")
+	}
+	return result, nil
+}
+
+func pdfPageLines(page pdf.Page) (lines []pdfLine, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF page: %v", r)
+		}
+	}()
+
+	texts := page.Content().Text
+	sort.SliceStable(texts, func(i, j int) bool {
+		if math.Abs(texts[i].Y-texts[j].Y) > 1 {
+			return texts[i].Y > texts[j].Y
+		}
+		return texts[i].X < texts[j].X
+	})
+
+	var builder strings.Builder
+	var current pdfLine
+	var prev pdf.Text
+	boldChars, chars := 0, 0
+	flush := func() {
+		current.text = strings.TrimSpace(builder.String())
+		current.bold = chars > 0 && boldChars*2 > chars
+		if current.text != "" {
+			lines = append(lines, current)
+		}
+		builder.Reset()
+		current = pdfLine{}
+		boldChars, chars = 0, 0
+	}
+
+	for i, text := range texts {
+		if i > 0 && math.Abs(text.Y-prev.Y) > 1 {
+			flush()
+		}
+		if builder.Len() == 0 {
+			current.y = text.Y
+		} else if text.X-(prev.X+prev.W) > 0.15*text.FontSize && !strings.HasSuffix(builder.String(), " ") {
+			// Words are often positioned individually without a space glyph.
+			builder.WriteString(" ")
+		}
+		builder.WriteString(text.S)
+		current.fontSize = math.Max(current.fontSize, text.FontSize)
+		if strings.TrimSpace(text.S) != "" {
+			chars++
+			if strings.Contains(strings.ToLower(text.Font), "bold") {
+				boldChars++
+			}
+		}
+		prev = text
+	}
+	flush()
+
+	return lines, nil
+}
+
+// pdfBodyFontSize is the font size most of the text is set in.
+func pdfBodyFontSize(pages [][]pdfLine) float64 {
+	weights := map[float64]int{}
+	for _, lines := range pages {
+		for _, line := range lines {
+			weights[math.Round(line.fontSize)] += len(line.text)
+		}
+	}
+
+	bodySize, bestWeight := 0.0, 0
+	for size, weight := range weights {
+		if weight > bestWeight || (weight == bestWeight && size < bodySize) {
+			bodySize, bestWeight = size, weight
+		}
+	}
+	return bodySize
+}
+
+func pdfLineToMarkdown(line pdfLine, bodySize float64) string {
+	text := line.text
+
+	if item, ok := bulletItem(text); ok {
+		return item
+	}
+
+	words := len(strings.Fields(text))
+	switch {
+	case bodySize > 0 && line.fontSize >= bodySize*1.5 && words <= 12:
+		return "# " + text
+	case bodySize > 0 && line.fontSize >= bodySize*1.15 && words <= 12:
+		return "## " + text
+	case line.bold && words <= 8 && isUpperCase(text):
+		return "## " + text
+	}
+	return text
+}
+
+// pdfPlainText is the fallback for PDFs whose layout cannot be read.
+func pdfPlainText(reader *pdf.Reader) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF text: %v", r)
+		}
+	}()
+
+	plainText, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("failed to read PDF text: %w", err)
+	}
+	textBytes, err := io.ReadAll(plainText)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PDF text: %w", err)
+	}
+
+	text = strings.TrimSpace(string(textBytes))
+	if text == "" {
+		return "", errors.New("the PDF contains no extractable text, it may be a scanned image")
+	}
+	return text, nil
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// A CV job goes queued -> converting -> embedding -> done. A failed attempt
+// goes back to queued until the attempts run out, then the job is failed.
+const (
+	CVJobQueued     = "queued"
+	CVJobConverting = "converting"
+	CVJobEmbedding  = "embedding"
+	CVJobDone       = "done"
+	CVJobFailed     = "failed"
+)
+
+type CVJob struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"-"`
+	FileName  string    `json:"file_name"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (job CVJob) Finished() bool {
+	return job.Status == CVJobDone || job.Status == CVJobFailed
+}
+
+type CVJobConfig struct {
+	Workers      int
+	MaxAttempts  int
+	RetryDelay   time.Duration
+	PollInterval time.Duration
+	// A job whose worker died is picked up again once its lease expires.
+	Lease time.Duration
+}
+
+func NewCVJobConfig() CVJobConfig {
+	return CVJobConfig{
+		Workers:      max(EnvInt("CV_WORKERS", 2), 1),
+		MaxAttempts:  max(EnvInt("CV_JOB_MAX_ATTEMPTS", 3), 1),
+		RetryDelay:   EnvDuration("CV_JOB_RETRY_DELAY", 30*time.Second),
+		PollInterval: EnvDuration("CV_JOB_POLL_INTERVAL", 5*time.Second),
+		Lease:        EnvDuration("CV_JOB_LEASE", 10*time.Minute),
+	}
+}
+
+// GetCVJob returns the job only if it belongs to the user.
+func GetCVJob(db DB, jobId int, userId int) (CVJob, error) {
+	var job CVJob
+	err := db.QueryRow(
+		context.Background(),
+		"SELECT id, user_id, file_name, status, attempts, error, created_at, updated_at FROM cv_jobs WHERE id = $1 AND user_id = $2",
+		jobId, userId).Scan(
+		&job.Id, &job.UserId, &job.FileName, &job.Status, &job.Attempts, &job.Error, &job.CreatedAt, &job.UpdatedAt)
+	return job, err
+}
+
+func GetLatestCVJob(db DB, userId int) (CVJob, error) {
+	var job CVJob
+	err := db.QueryRow(
+		context.Background(),
+		"SELECT id, user_id, file_name, status, attempts, error, created_at, updated_at FROM cv_jobs WHERE user_id = $1 ORDER BY id DESC LIMIT 1",
+		userId).Scan(
+		&job.Id, &job.UserId, &job.FileName, &job.Status, &job.Attempts, &job.Error, &job.CreatedAt, &job.UpdatedAt)
+	return job, err
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"time"
+)
+
+// CVVersion is one uploaded CV of an employee. Original is only loaded when
+// the file is downloaded.
+type CVVersion struct {
+	Id           int
+	UserId       int
+	FileName     string
+	Original     []byte
+	Markdown     string
+	UploadedAt   time.Time
+	UploadedBy   int
+	UploaderName string
+	IsActive     bool
+}
+
+// CVSource is a CV chunk cited in an answer, with the version it was cut from.
+// Before, Cited and After split the markdown of the version around the
+// chunk, Cited is empty when the chunk was not found in it.
+type CVSource struct {
+	ChunkId      int
+	EmployeeName string
+	Section      string
+	Excerpt      string
+	Version      CVVersion
+	Before       string
+	Cited        string
+	After        string
+}
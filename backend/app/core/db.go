@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB is satisfied by the pool, a connection acquired from it and a
+// transaction, so helpers work the same inside and outside a transaction.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Pool is the connection pool shared by all requests.
+type Pool struct {
+	*pgxpool.Pool
+	AcquireTimeout time.Duration
+}
+
+// NewPool connects to the database described by the DB_* env variables.
+// The pool is sized by DB_POOL_MAX_CONNS and DB_POOL_MIN_CONNS, idle
+// connections are checked every DB_POOL_HEALTH_CHECK_PERIOD and requests give
+// up waiting for a free connection after DB_POOL_ACQUIRE_TIMEOUT.
+func NewPool() (*Pool, error) {
+	containerName := os.Getenv("DB_CONTAINER_NAME")
+	user := os.Getenv("DB_USER")
+	pass := os.Getenv("DB_PWD")
+	schema := os.Getenv("DB_SCHEMA")
+	port := os.Getenv("DB_PORT")
+
+	url := "postgres://" + user + ":" + pass + "@" + containerName + ":" + port + "/" + schema
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+	config.MaxConns = int32(EnvInt("DB_POOL_MAX_CONNS", 20))
+	config.MinConns = int32(EnvInt("DB_POOL_MIN_CONNS", 2))
+	config.HealthCheckPeriod = EnvDuration("DB_POOL_HEALTH_CHECK_PERIOD", 30*time.Second)
+	config.MaxConnIdleTime = EnvDuration("DB_POOL_MAX_CONN_IDLE_TIME", 5*time.Minute)
+	config.ConnConfig.ConnectTimeout = EnvDuration("DB_CONNECT_TIMEOUT", 5*time.Second)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail at startup instead of at the first request.
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnConfig.ConnectTimeout)
+	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return &Pool{
+		Pool:           pool,
+		AcquireTimeout: EnvDuration("DB_POOL_ACQUIRE_TIMEOUT", 5*time.Second),
+	}, nil
+}
+
+// Acquire takes a connection from the pool, waiting at most AcquireTimeout.
+// The connection must be released with Release.
+func (p *Pool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.AcquireTimeout)
+	defer cancel()
+	return p.Pool.Acquire(ctx)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"strings"
+)
+
+const (
+	DiffSame    = "same"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+)
+
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// Above this many line pairs the LCS table gets too large, the changed middle
+// part is then shown as removed and added as a whole.
+const maxDiffCells = 4_000_000
+
+// DiffLines compares two texts line by line using the longest common
+// subsequence of lines.
+func DiffLines(oldText string, newText string) []DiffLine {
+	oldLines := strings.Split(strings.ReplaceAll(oldText, "\r\n", "\n"), "\n")
+	newLines := strings.Split(strings.ReplaceAll(newText, "\r\n", "\n"), "\n")
+
+	// Common leading and trailing lines do not need the table.
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	var diff []DiffLine
+	for _, line := range oldLines[:prefix] {
+		diff = append(diff, DiffLine{Kind: DiffSame, Text: line})
+	}
+	diff = append(diff, diffMiddle(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])...)
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		diff = append(diff, DiffLine{Kind: DiffSame, Text: line})
+	}
+	return diff
+}
+
+func diffMiddle(oldLines []string, newLines []string) []DiffLine {
+	var diff []DiffLine
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		for _, line := range oldLines {
+			diff = append(diff, DiffLine{Kind: DiffRemoved, Text: line})
+		}
+		for _, line := range newLines {
+			diff = append(diff, DiffLine{Kind: DiffAdded, Text: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the LCS of oldLines[i:] and newLines[j:].
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, DiffLine{Kind: DiffSame, Text: oldLines[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Kind: DiffRemoved, Text: oldLines[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Kind: DiffAdded, Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, DiffLine{Kind: DiffRemoved, Text: oldLines[i]})
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, DiffLine{Kind: DiffAdded, Text: newLines[j]})
+	}
+	return diff
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns texts into embedding vectors, one vector per input text and
+// in the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedder builds the embedder selected by the EMB_PROVIDER env variable
+// ("ollama" by default, "openai" or "fake").
+func NewEmbedder() (Embedder, error) {
+	batchSize, err := strconv.Atoi(EnvOrDefault("EMB_BATCH_SIZE", "16"))
+	if err != nil || batchSize < 1 {
+		log.Println("Could not convert the EMB_BATCH_SIZE env variable to a positive int.")
+		batchSize = 16
+	}
+
+	switch provider := os.Getenv("EMB_PROVIDER"); provider {
+	case "", "ollama":
+		apiURL := EnvOrDefault("OLLAMA_EMB_API", "http://localhost:11434/api/embed")
+		// The legacy endpoint only takes a single prompt per call.
+		if strings.HasSuffix(apiURL, "/api/embeddings") {
+			log.Println("OLLAMA_EMB_API points to the legacy /api/embeddings endpoint, using /api/embed instead.")
+			apiURL = strings.TrimSuffix(apiURL, "/api/embeddings") + "/api/embed"
+		}
+		return &OllamaEmbedder{
+			URL:       apiURL,
+			Model:     EnvOrDefault("OLLAMA_EMB_MODEL", "nomic-embed-text"),
+			BatchSize: batchSize,
+		}, nil
+	case "openai":
+		return &OpenAIEmbedder{
+			URL:       EnvOrDefault("OPENAI_EMB_API", "http://localhost:8000/v1/embeddings"),
+			Model:     os.Getenv("OPENAI_EMB_MODEL"),
+			APIKey:    os.Getenv("OPENAI_API_KEY"),
+			BatchSize: batchSize,
+		}, nil
+	case "fake":
+		dimension, err := strconv.Atoi(EnvOrDefault("FAKE_EMB_DIM", "768"))
+		if err != nil || dimension < 1 {
+			return nil, fmt.Errorf("invalid FAKE_EMB_DIM %q", os.Getenv("FAKE_EMB_DIM"))
+		}
+		return &FakeEmbedder{Dimension: dimension}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMB_PROVIDER %q", provider)
+	}
+}
+
+// GetEmbedding embeds a single text.
+func GetEmbedding(embedder Embedder, text string) ([]float32, error) {
+	embeddings, err := embedder.Embed(context.Background(), []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// CheckEmbeddingDimension makes sure the vectors produced by the embedder fit
+// in the cv_chunks.embedding column, so a wrongly configured model is caught at
+// startup instead of at the first CV upload.
+func CheckEmbeddingDimension(db DB, embedder Embedder) error {
+	var columnDimension int
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'cv_chunks'::regclass AND attname = 'embedding'`).Scan(&columnDimension)
+	if err != nil {
+		return fmt.Errorf("failed to read the cv_chunks.embedding dimension: %w", err)
+	}
+
+	embedding, err := GetEmbedding(embedder, "dimension check")
+	if err != nil {
+		return fmt.Errorf("failed to get a test embedding: %w", err)
+	}
+
+	if len(embedding) != columnDimension {
+		return fmt.Errorf("embedding model returns %d dimensions but cv_chunks.embedding is vector(%d)", len(embedding), columnDimension)
+	}
+	return nil
+}
+
+// Ollama /api/embed
+
+type OllamaEmbedder struct {
+	URL       string
+	Model     string
+	BatchSize int
+}
+
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedInBatches(texts, e.BatchSize, func(batch []string) ([][]float32, error) {
+		var embeddingResp struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		payload := map[string]any{
+			"model": e.Model,
+			"input": batch,
+		}
+		if err := postJSONAndDecode(ctx, e.URL, "", payload, &embeddingResp); err != nil {
+			return nil, err
+		}
+		return embeddingResp.Embeddings, nil
+	})
+}
+
+// OpenAI-compatible /v1/embeddings
+
+type OpenAIEmbedder struct {
+	URL       string
+	Model     string
+	APIKey    string
+	BatchSize int
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedInBatches(texts, e.BatchSize, func(batch []string) ([][]float32, error) {
+		var embeddingResp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		payload := map[string]any{
+			"model": e.Model,
+			"input": batch,
+		}
+		if err := postJSONAndDecode(ctx, e.URL, e.APIKey, payload, &embeddingResp); err != nil {
+			return nil, err
+		}
+
+		embeddings := make([][]float32, len(embeddingResp.Data))
+		for _, item := range embeddingResp.Data {
+			if item.Index < 0 || item.Index >= len(embeddings) {
+				return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+			}
+			embeddings[item.Index] = item.Embedding
+		}
+		return embeddings, nil
+	})
+}
+
+// FakeEmbedder produces deterministic bag-of-words vectors without a model
+// server. Texts sharing words get similar vectors, which is enough to exercise
+// retrieval end to end.
+type FakeEmbedder struct {
+	Dimension int
+}
+
+func (e *FakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding := make([]float32, e.Dimension)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			hash := fnv.New32a()
+			hash.Write([]byte(word))
+			embedding[hash.Sum32()%uint32(e.Dimension)] += 1
+		}
+
+		var norm float64
+		for _, value := range embedding {
+			norm += float64(value * value)
+		}
+		if norm == 0 {
+			// Keep the vector usable for cosine distance.
+			embedding[0] = 1
+			norm = 1
+		}
+		for j := range embedding {
+			embedding[j] /= float32(math.Sqrt(norm))
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+func embedInBatches(texts []string, batchSize int, embedBatch func([]string) ([][]float32, error)) ([][]float32, error) {
+	if batchSize < 1 {
+		batchSize = len(texts)
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+		batch, err := embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		for _, embedding := range batch {
+			if len(embedding) == 0 {
+				return nil, fmt.Errorf("received an empty embedding")
+			}
+			if len(embeddings) > 0 && len(embedding) != len(embeddings[0]) {
+				return nil, fmt.Errorf("embeddings have inconsistent dimensions %d and %d", len(embeddings[0]), len(embedding))
+			}
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func postJSONAndDecode(ctx context.Context, url string, apiKey string, payload any, result any) error {
+	// Embedding calls are not streamed, so they get a hard timeout.
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	resp, err := postJSON(ctx, url, apiKey, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Employment statuses of users.employment_status.
+const (
+	EmploymentActive  = "active"
+	EmploymentOnLeave = "on_leave"
+	EmploymentLeft    = "left"
+)
+
+var EmploymentStatuses = []string{EmploymentActive, EmploymentOnLeave, EmploymentLeft}
+
+// Seniority levels of users.seniority, most junior first.
+var SeniorityLevels = []string{"intern", "junior", "mid", "senior", "lead", "principal"}
+
+// EmployeeAttributes describe where an employee works and whether they can
+// be staffed. Department and location are free text, empty when unknown.
+type EmployeeAttributes struct {
+	Department       string
+	Location         string
+	Seniority        string
+	EmploymentStatus string
+}
+
+// Employee is a user as seen by the administrators managing the attributes.
+type Employee struct {
+	Id      int
+	Name    string
+	Email   string
+	IsAdmin bool
+	HasCV   bool
+	EmployeeAttributes
+}
+
+// EmployeeFilter restricts retrieval to some employees. An empty list allows
+// any value, values of one list are alternatives.
+type EmployeeFilter struct {
+	Departments []string
+	Locations   []string
+	Seniorities []string
+	Statuses    []string
+	// IncludeAdmins also searches the CVs of administrators, who use the
+	// assistant rather than get staffed by it.
+	IncludeAdmins bool
+}
+
+// DefaultEmployeeFilter leaves out the administrators and the people who left.
+func DefaultEmployeeFilter() EmployeeFilter {
+	return EmployeeFilter{Statuses: []string{EmploymentActive, EmploymentOnLeave}}
+}
+
+// Describe lists the restrictions of the filter for the model, "" when it
+// only has the defaults.
+func (filter EmployeeFilter) Describe() string {
+	var parts []string
+	if len(filter.Departments) > 0 {
+		parts = append(parts, "department "+strings.Join(filter.Departments, " or "))
+	}
+	if len(filter.Locations) > 0 {
+		parts = append(parts, "location "+strings.Join(filter.Locations, " or "))
+	}
+	if len(filter.Seniorities) > 0 {
+		parts = append(parts, "seniority "+strings.Join(filter.Seniorities, " or "))
+	}
+	if len(filter.Statuses) == 1 && filter.Statuses[0] == EmploymentActive {
+		parts = append(parts, "available")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// employeeFilterSQL returns the conditions on the users table for a filter
+// whose args start at parameter first.
+func employeeFilterSQL(first int) string {
+	return fmt.Sprintf(
+		`(cardinality($%[1]d::text[]) = 0 OR lower(users.department) = ANY($%[1]d::text[]))
+		AND (cardinality($%[2]d::text[]) = 0 OR lower(users.location) = ANY($%[2]d::text[]))
+		AND (cardinality($%[3]d::text[]) = 0 OR users.seniority = ANY($%[3]d::text[]))
+		AND (cardinality($%[4]d::text[]) = 0 OR users.employment_status = ANY($%[4]d::text[]))
+		AND ($%[5]d::bool OR NOT users.isAdmin)`,
+		first, first+1, first+2, first+3, first+4)
+}
+
+// args returns the parameters of employeeFilterSQL. Departments and
+// locations compare case-insensitively.
+func (filter EmployeeFilter) args() []any {
+	lower := func(values []string) []string {
+		lowered := []string{}
+		for _, value := range values {
+			lowered = append(lowered, strings.ToLower(value))
+		}
+		return lowered
+	}
+	return []any{
+		lower(filter.Departments),
+		lower(filter.Locations),
+		append([]string{}, filter.Seniorities...),
+		append([]string{}, filter.Statuses...),
+		filter.IncludeAdmins,
+	}
+}
+
+// ParseEmployeeFilter narrows base with the departments, locations and
+// seniority levels named in a question, so "only Sofia-based backend
+// engineers" searches the Backend department in Sofia. Names only count
+// where they are used as such, a Data department does not restrict "who has
+// data engineering experience". Asking for available people leaves out those
+// on leave.
+func ParseEmployeeFilter(db DB, text string, base EmployeeFilter) (EmployeeFilter, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	filter := base
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT DISTINCT 'department', department FROM users WHERE department <> ''
+		UNION SELECT DISTINCT 'location', location FROM users WHERE location <> ''`)
+	if err != nil {
+		return base, err
+	}
+	defer rows.Close()
+
+	var departments, locations []string
+	for rows.Next() {
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			return base, err
+		}
+		named := false
+		for _, start := range phraseStarts(words, value) {
+			end := start + len(phraseWords(value))
+			if kind == "department" && namesDepartment(words, end) || kind == "location" && namesLocation(words, start, end) {
+				named = true
+			}
+		}
+		if !named {
+			continue
+		}
+		if kind == "department" {
+			departments = append(departments, value)
+		} else {
+			locations = append(locations, value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return base, err
+	}
+
+	var seniorities []string
+	for i := range words {
+		level := seniorityAt(words, i)
+		if level != "" && !slices.Contains(seniorities, level) {
+			seniorities = append(seniorities, level)
+		}
+	}
+
+	// Values named in the question replace the ones of base.
+	if len(departments) > 0 {
+		filter.Departments = departments
+	}
+	if len(locations) > 0 {
+		filter.Locations = locations
+	}
+	if len(seniorities) > 0 {
+		filter.Seniorities = seniorities
+	}
+	if containsPhrase(words, "available") {
+		filter.Statuses = []string{EmploymentActive}
+	}
+	return filter, nil
+}
+
+var seniorityVerbContext = toSet("to", "can", "could", "will", "would", "should", "must", "who")
+
+// Words a seniority level describes, as in "mid-level" or "senior engineers".
+var seniorityNouns = toSet(
+	"level", "engineer", "developer", "dev", "programmer", "architect", "consultant", "designer", "tester",
+	"analyst", "manager", "administrator", "admin", "specialist", "scientist", "expert", "lead", "person",
+	"people", "employee", "colleague", "candidate", "member", "profile", "role", "position", "staff", "hire",
+	"one", "someone", "somebody",
+)
+
+// seniorityAt returns the seniority level the word at i asks for, "" when
+// it is not used as one. Level words are also common in other phrases, "mid
+// March" or "who can lead the team", so they only count next to a role or
+// level word, as in "senior Java developer", "tech lead", "seniority
+// senior", or as nouns like "juniors".
+func seniorityAt(words []string, i int) string {
+	word := words[i]
+	level := ""
+	for _, candidate := range SeniorityLevels {
+		if word == candidate || word == candidate+"s" {
+			level = candidate
+		}
+	}
+	if level == "" {
+		return ""
+	}
+	if i > 0 {
+		switch previous := words[i-1]; {
+		case seniorityVerbContext[previous]:
+			return ""
+		case previous == "level" || previous == "seniority":
+			return level
+		case level == "lead" && (previous == "tech" || previous == "team"):
+			return level
+		}
+	}
+	if level == "intern" || word == "juniors" || word == "seniors" {
+		return level
+	}
+
+	// A role noun may follow after a few words naming the field, up to a
+	// word like "in" or "for" that starts another phrase.
+	for _, next := range words[i+1 : min(i+4, len(words))] {
+		if seniorityNouns[next] || seniorityNouns[strings.TrimSuffix(next, "s")] {
+			return level
+		}
+		if keywordStopWords[next] {
+			break
+		}
+	}
+	return ""
+}
+
+// Words after a department name saying it is one, as in "the Data team".
+// Role nouns count too, "backend engineers".
+var departmentNouns = toSet("department", "dept", "team", "division", "unit", "group")
+
+// namesDepartment reports whether the department name ending before word end
+// is used as one.
+func namesDepartment(words []string, end int) bool {
+	if end >= len(words) {
+		return false
+	}
+	next := words[end]
+	return departmentNouns[next] || seniorityNouns[next] || seniorityNouns[strings.TrimSuffix(next, "s")]
+}
+
+// Words around a location name saying it is one, as in "based in Berlin",
+// "Sofia-based" or "the Berlin office".
+var (
+	locationPrepositions = toSet("in", "from", "at", "near")
+	locationNouns        = toSet("based", "office", "offices", "area")
+)
+
+// namesLocation reports whether the location name at words[start:end] is
+// used as one.
+func namesLocation(words []string, start int, end int) bool {
+	if start > 0 && locationPrepositions[words[start-1]] {
+		return true
+	}
+	return end < len(words) && locationNouns[words[end]]
+}
+
+// phraseWords splits phrase into lowercase words like the questions.
+func phraseWords(phrase string) []string {
+	return strings.FieldsFunc(strings.ToLower(phrase), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// phraseStarts returns where the lowercase words contain the words of
+// phrase in a row.
+func phraseStarts(words []string, phrase string) []int {
+	parts := phraseWords(phrase)
+	if len(parts) == 0 {
+		return nil
+	}
+	var starts []int
+	for i := 0; i+len(parts) <= len(words); i++ {
+		match := true
+		for j, part := range parts {
+			if words[i+j] != part {
+				match = false
+				break
+			}
+		}
+		if match {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// containsPhrase reports whether the lowercase words contain the words of
+// phrase in a row.
+func containsPhrase(words []string, phrase string) bool {
+	return len(phraseStarts(words, phrase)) > 0
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvOrDefault returns the env variable key, or def when it is not set.
+func EnvOrDefault(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// EnvInt parses the env variable key as an int, falling back to def.
+func EnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Could not convert the %s env variable to int, using %d.", key, def)
+		return def
+	}
+	return number
+}
+
+// EnvDuration parses the env variable key as a duration like "30s",
+// falling back to def.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Could not parse the %s env variable as a duration, using %s.", key, def)
+		return def
+	}
+	return duration
+}
+
+// EnvFloat parses the env variable key as a float, falling back to def.
+func EnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Could not convert the %s env variable to float, using %g.", key, def)
+		return def
+	}
+	return number
+}
@@ -1,54 +1,81 @@
 package core
 
 import (
-	"context"
 	"log"
 	"net/http"
-	"github.com/jackc/pgx/v5"
 	"github.com/gorilla/websocket"
 )
 
-func WithDBConnection(handler func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn)) http.HandlerFunc {
+func WithDBConnection(pool *Pool, handler func(w http.ResponseWriter, r *http.Request, db DB)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := Connect()
+		conn, err := pool.Acquire(r.Context())
 		if err != nil {
 			log.Printf("Database connection failed: %v", err)
 			http.Redirect(w, r, "/signin?error=databaseError", http.StatusSeeOther)
 			return
 		}
-		defer conn.Close(context.Background())
+		defer conn.Release()
 		handler(w, r, conn)
 	}
 }
 
-func WithAuthorization(handler func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user User)) http.HandlerFunc {
-	return WithDBConnection(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn) {
-		if err := Authorize(conn, r); err != nil {
-			log.Printf("Authorization failed: %v", err)
-			http.Redirect(w, r, "/signin", http.StatusSeeOther)
+func WithAuthorization(pool *Pool, handler func(w http.ResponseWriter, r *http.Request, db DB, user User)) http.HandlerFunc {
+	return WithDBConnection(pool, func(w http.ResponseWriter, r *http.Request, db DB) {
+		user, ok := authorizeUser(db, w, r)
+		if !ok {
 			return
 		}
+		handler(w, r, db, user)
+	})
+}
 
-		emailCookie, err := r.Cookie("user_email")
+// WithPoolAuthorization authorizes like WithAuthorization but hands the pool
+// itself to the handler instead of a connection held for the whole request.
+// Long-lived handlers like the chat socket use it so they do not pin a pooled
+// connection while idle.
+func WithPoolAuthorization(pool *Pool, handler func(w http.ResponseWriter, r *http.Request, db DB, user User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := pool.Acquire(r.Context())
 		if err != nil {
-			log.Printf("User's email is not in the cookie: %v", err)
-			http.Redirect(w, r, "/signin?error=cookieError", http.StatusSeeOther)
+			log.Printf("Database connection failed: %v", err)
+			http.Redirect(w, r, "/signin?error=databaseError", http.StatusSeeOther)
 			return
 		}
-
-		user, err := GetUserData(conn, emailCookie.Value)
-		if err != nil {
-			log.Printf("Retrieving user details failed: %v", err)
-			http.Redirect(w, r, "/signin?error=databaseError", http.StatusSeeOther)
+		user, ok := authorizeUser(conn, w, r)
+		conn.Release()
+		if !ok {
 			return
 		}
+		handler(w, r, pool, user)
+	}
+}
 
-		handler(w, r, conn, user)
-	})
+func authorizeUser(db DB, w http.ResponseWriter, r *http.Request) (User, bool) {
+	if err := Authorize(db, r); err != nil {
+		log.Printf("Authorization failed: %v", err)
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return User{}, false
+	}
+
+	emailCookie, err := r.Cookie("user_email")
+	if err != nil {
+		log.Printf("User's email is not in the cookie: %v", err)
+		http.Redirect(w, r, "/signin?error=cookieError", http.StatusSeeOther)
+		return User{}, false
+	}
+
+	user, err := GetUserData(db, emailCookie.Value)
+	if err != nil {
+		log.Printf("Retrieving user details failed: %v", err)
+		http.Redirect(w, r, "/signin?error=databaseError", http.StatusSeeOther)
+		return User{}, false
+	}
+
+	return user, true
 }
 
-func RedirectIfAuthorized(conn *pgx.Conn, w http.ResponseWriter, r *http.Request, redirectPath string) bool {
-	if err := Authorize(conn, r); err == nil {
+func RedirectIfAuthorized(db DB, w http.ResponseWriter, r *http.Request, redirectPath string) bool {
+	if err := Authorize(db, r); err == nil {
 		log.Println("User already signed in. Redirecting to", redirectPath)
 		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
 		return true
@@ -56,8 +83,8 @@ func RedirectIfAuthorized(conn *pgx.Conn, w http.ResponseWriter, r *http.Request
 	return false
 }
 
-func WithWebSocket(handler func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user User, ws *websocket.Conn)) http.HandlerFunc {
-	return WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user User) {
+func WithWebSocket(pool *Pool, handler func(w http.ResponseWriter, r *http.Request, db DB, user User, ws *websocket.Conn)) http.HandlerFunc {
+	return WithPoolAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db DB, user User) {
 		ws, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("WebSocket upgrade failed: %v", err)
@@ -65,6 +92,6 @@ func WithWebSocket(handler func(w http.ResponseWriter, r *http.Request, conn *pg
 		}
 		defer ws.Close()
 		
-		handler(w, r, conn, user, ws)
+		handler(w, r, db, user, ws)
 	})
 }
@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"github.com/jackc/pgx/v5"
 
 	"crypto/rand"
 	"encoding/base64"
@@ -26,8 +25,6 @@ import (
 	"time"
 
 	"github.com/pgvector/pgvector-go"
-	"bytes"
-	"encoding/json"
 )
 
 type User struct {
@@ -43,22 +40,6 @@ type User struct {
 	CV string
 }
 
-func Connect() (*pgx.Conn, error) {
-	containerName := os.Getenv("DB_CONTAINER_NAME")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PWD")
-	schema := os.Getenv("DB_SCHEMA")
-	port := os.Getenv("DB_PORT")
-
-	url := "postgres://" + user + ":" + pass + "@" + containerName + ":" + port + "/" + schema
-
-	conn, err := pgx.Connect(context.Background(), url)
-	if err != nil {
-		return nil, err
-	}
-	return conn, nil
-}
-
 func HashPassword(password string) string {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -72,8 +53,8 @@ func CheckPasswordHash(password string, hashedPassword string) error {
 	return err
 }
 
-func CountUsers(conn *pgx.Conn) (int, error) {
-	rows, err := conn.Query(context.Background(), "SELECT count(id) FROM users")
+func CountUsers(db DB) (int, error) {
+	rows, err := db.Query(context.Background(), "SELECT count(id) FROM users")
 	if err != nil {
 		return -1, err
 	}
@@ -97,9 +78,9 @@ func GenerateToken(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
-func GetUserData(conn *pgx.Conn, email string) (User, error) {
+func GetUserData(db DB, email string) (User, error) {
 	var user User
-	err := conn.QueryRow(
+	err := db.QueryRow(
 		context.Background(),
 		"SELECT id, name, email, passwordHash, sessionToken, csrfToken, isAdmin, cv FROM users WHERE email=$1", email).Scan(
 			&user.Id, &user.Name, &user.Email, &user.PasswordHash, &user.SessionToken, &user.CSRFToken, &user.IsAdmin, &user.CV)
@@ -109,7 +90,7 @@ func GetUserData(conn *pgx.Conn, email string) (User, error) {
 	return user, nil
 }
 
-func Authorize(con *pgx.Conn, r *http.Request) error {
+func Authorize(db DB, r *http.Request) error {
 	var AuthError = errors.New("Unauthorized")
 	emailCookie, err := r.Cookie("user_email")
 	if err != nil {
@@ -117,7 +98,7 @@ func Authorize(con *pgx.Conn, r *http.Request) error {
 	}
 	email := emailCookie.Value
 
-	user, err := GetUserData(con, email)
+	user, err := GetUserData(db, email)
 	if err != nil {
 		return AuthError
 	}
@@ -140,9 +121,9 @@ func Authorize(con *pgx.Conn, r *http.Request) error {
 	return nil
 }
 
-func UpdateUserTokens(conn *pgx.Conn, user User) error {
+func UpdateUserTokens(db DB, user User) error {
 	// Start a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := db.Begin(context.Background())
 	if err != nil {
 		return err
 	}
@@ -164,46 +145,33 @@ func UpdateUserTokens(conn *pgx.Conn, user User) error {
 	return nil
 }
 
-func ReceiveFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+// ReceiveFile reads the uploaded file and its name. The file type is not
+// checked here, ConvertCV sniffs the contents instead of trusting the
+// browser's Content-Type header.
+func ReceiveFile(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
 	// Check content type first
 	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "multipart/form-data") {
-		return nil, fmt.Errorf("expected multipart/form-data, got %s", ct)
+		return nil, "", fmt.Errorf("expected multipart/form-data, got %s", ct)
 	}
 
 	// Parse form
 	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32 MB max memory
-		return nil, fmt.Errorf("failed to parse form: %w", err)
+		return nil, "", fmt.Errorf("failed to parse form: %w", err)
 	}
 
 	// Get uploaded file
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		return nil, fmt.Errorf("file upload error: %w", err)
+		return nil, "", fmt.Errorf("file upload error: %w", err)
 	}
 	defer file.Close()
 
-	// Validate file type - allow DOCX
-	contentType := header.Header.Get("Content-Type")
-	allowedTypes := []string{
-		"application/vnd.openxmlformats-officedocument.wordprocessingml.document", // DOCX
-	}
-
-	validType := false
-	for _, t := range allowedTypes {
-		if contentType == t {
-			validType = true
-			break
-		}
-	}
-
-	// Also check file extension as fallback
-	ext := filepath.Ext(header.Filename)
-	if !validType && ext != ".docx" {
-		return nil, errors.New("only DOCX files are allowed")
-	}
-
 	// Read file directly into byte slice
-	return io.ReadAll(file)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, header.Filename, nil
 }
 
 func DocxToMarkDown(docxBytes []byte) (string, error) {
@@ -280,82 +248,116 @@ func GenerateAndSetTokens(w http.ResponseWriter, user *User) error {
 	return nil
 }
 
-func GetRelevantCVChunks(conn *pgx.Conn, queryEmbedding []float32, limit int) ([]string, error) {
+type CVChunk struct {
+	Id       int
+	UserId   int
+	Name     string
+	Section  string
+	Chunk    string
+	Distance float64
+	// Score is the fused rank of the chunk, higher is better.
+	Score     float64
+	Embedding []float32
+}
+
+// GetRelevantCVChunks finds the chunks of the active CVs best matching a
+// question, fusing the ranks of the vector search over queryEmbedding and of
+// the full-text search over queryText as configured. Chunks only the vector
+// search found are left out when less similar to the question than
+// config.MinSimilarity. The employees config.Filter excludes are left out
+// before either search ranks anything.
+func GetRelevantCVChunks(db DB, queryEmbedding []float32, queryText string, limit int, config RetrievalConfig) ([]CVChunk, error) {
     vec := pgvector.NewVector(queryEmbedding)
-    rows, err := conn.Query(
+    rows, err := db.Query(
         context.Background(),
-	`SELECT 'Employee: ' || name || chr(10) || chunk 
-        FROM cv_chunks join users on users.id = cv_chunks.user_id
-        ORDER BY embedding <=> $1 
-        LIMIT $2`,
-        vec, limit,
+	`WITH semantic AS (
+            SELECT id, row_number() OVER (ORDER BY distance) AS rank
+            FROM (
+                SELECT cv_chunks.id, cv_chunks.embedding <=> $1 AS distance
+                FROM cv_chunks
+                JOIN cv_versions ON cv_versions.id = cv_chunks.version_id AND cv_versions.is_active
+                JOIN users ON users.id = cv_chunks.user_id
+                WHERE $4::float8 > 0 AND ` + employeeFilterSQL(9) + `
+                ORDER BY distance
+                LIMIT $3
+            ) nearest
+        ), keyword AS (
+            SELECT id, row_number() OVER (ORDER BY text_rank DESC) AS rank
+            FROM (
+                SELECT cv_chunks.id, ts_rank_cd(cv_chunks.search, query, 1) AS text_rank
+                FROM cv_chunks
+                JOIN cv_versions ON cv_versions.id = cv_chunks.version_id AND cv_versions.is_active
+                JOIN users ON users.id = cv_chunks.user_id,
+                to_tsquery('simple', $2) AS query
+                WHERE $2 <> '' AND $5::float8 > 0 AND cv_chunks.search @@ query AND ` + employeeFilterSQL(9) + `
+                ORDER BY text_rank DESC
+                LIMIT $3
+            ) matching
+        )
+        SELECT cv_chunks.id, users.id, COALESCE(users.name, ''), cv_chunks.section, cv_chunks.chunk, cv_chunks.embedding <=> $1 AS distance,
+            COALESCE($4::float8 / ($6::float8 + semantic.rank), 0) + COALESCE($5::float8 / ($6::float8 + keyword.rank), 0) AS score,
+            cv_chunks.embedding
+        FROM semantic
+        FULL OUTER JOIN keyword ON keyword.id = semantic.id
+        JOIN cv_chunks ON cv_chunks.id = COALESCE(semantic.id, keyword.id)
+        JOIN users ON users.id = cv_chunks.user_id
+        WHERE keyword.id IS NOT NULL OR 1 - (cv_chunks.embedding <=> $1) >= $8::float8
+        ORDER BY score DESC, distance
+        LIMIT $7`,
+        append([]any{vec, KeywordQuery(queryText), config.Candidates, config.VectorWeight, config.KeywordWeight, float64(config.RRFK), limit,
+            config.MinSimilarity}, config.Filter.args()...)...,
     )
     if err != nil {
         return nil, fmt.Errorf("failed to query CV chunks: %w", err)
     }
     defer rows.Close()
 
-    var chunks []string
+    var chunks []CVChunk
     for rows.Next() {
-        var chunk string
-        if err := rows.Scan(&chunk); err != nil {
+        var chunk CVChunk
+        var embedding pgvector.Vector
+        if err := rows.Scan(&chunk.Id, &chunk.UserId, &chunk.Name, &chunk.Section, &chunk.Chunk, &chunk.Distance, &chunk.Score, &embedding); err != nil {
             return chunks, err
         }
+        chunk.Embedding = embedding.Slice()
         chunks = append(chunks, chunk)
     }
-    return chunks, nil
+    return chunks, rows.Err()
 }
 
-func GetEmbedding(text string) ([]float32, error) {
-	model := os.Getenv("OLLAMA_EMB_MODEL")
-	if model == "" {
-		model = "nomic-embed-text"
-	}
-	
-	apiURL := os.Getenv("OLLAMA_EMB_API")
-	if apiURL == "" {
-		apiURL = "http://localhost:11434/api/embeddings"
-	}
-
-	// Create request payload
-	payload := map[string]string{
-		"model":  model,
-		"prompt": text,
-	}
-	
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+// GetCVChunks loads chunks of the active CVs by id, in the order of ids.
+// Chunks of replaced CVs and of employees the filter excludes are skipped.
+func GetCVChunks(db DB, ids []int, filter EmployeeFilter) ([]CVChunk, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT cv_chunks.id, users.id, COALESCE(users.name, ''), cv_chunks.section, cv_chunks.chunk
+		FROM cv_chunks
+		JOIN cv_versions ON cv_versions.id = cv_chunks.version_id AND cv_versions.is_active
+		JOIN users ON users.id = cv_chunks.user_id
+		WHERE cv_chunks.id = ANY($1) AND `+employeeFilterSQL(2),
+		append([]any{ids}, filter.args()...)...)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	defer rows.Close()
 
-	// Send request with timeout
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+	found := map[int]CVChunk{}
+	for rows.Next() {
+		var chunk CVChunk
+		if err := rows.Scan(&chunk.Id, &chunk.UserId, &chunk.Name, &chunk.Section, &chunk.Chunk); err != nil {
+			return nil, err
+		}
+		found[chunk.Id] = chunk
 	}
-	defer resp.Body.Close()
-
-	// Check for successful response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error [%d]: %s", resp.StatusCode, string(body))
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Parse response
-	var embeddingResp struct {
-		Embedding []float32 `json:"embedding"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	var chunks []CVChunk
+	for _, id := range ids {
+		if chunk, ok := found[id]; ok {
+			chunks = append(chunks, chunk)
+		}
 	}
-
-	return embeddingResp.Embedding, nil
+	return chunks, nil
 }
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChatProvider is a model server able to stream a chat completion.
+// onToken is called for every piece of generated text as it arrives and may be
+// nil. Returning an error from onToken stops the stream. The text generated so
+// far is always returned, also when ctx is cancelled mid-response.
+type ChatProvider interface {
+	StreamChat(ctx context.Context, req ChatRequest, onToken func(string) error) (string, error)
+}
+
+type ChatRequest struct {
+	Messages []ChatMessage
+	// Format is a JSON schema the reply has to follow, nil for free text.
+	Format json.RawMessage
+}
+
+// NewChatProvider builds the provider selected by the LLM_PROVIDER env variable
+// ("ollama" by default, "openai" or "fake").
+func NewChatProvider() (ChatProvider, error) {
+	switch provider := os.Getenv("LLM_PROVIDER"); provider {
+	case "", "ollama":
+		numCTX, err := strconv.Atoi(os.Getenv("OLLAMA_CTX"))
+		if err != nil {
+			log.Println("Could not convert the OLLAMA_CTX env variable to int.")
+			numCTX = 4096
+		}
+		return &OllamaProvider{
+			URL:    EnvOrDefault("OLLAMA_API", "http://localhost:11434/api/chat"),
+			Model:  os.Getenv("OLLAMA_MODEL"),
+			NumCtx: numCTX,
+		}, nil
+	case "openai":
+		return &OpenAIProvider{
+			URL:    EnvOrDefault("OPENAI_API", "http://localhost:8000/v1/chat/completions"),
+			Model:  os.Getenv("OPENAI_MODEL"),
+			APIKey: os.Getenv("OPENAI_API_KEY"),
+		}, nil
+	case "fake":
+		return &FakeChatProvider{Reply: os.Getenv("FAKE_LLM_REPLY")}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM_PROVIDER %q", provider)
+	}
+}
+
+// Ollama /api/chat
+
+type OllamaProvider struct {
+	URL    string
+	Model  string
+	NumCtx int
+}
+
+type OllamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ChatMessage   `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  struct {
+		NumCtx int `json:"num_ctx"`
+	} `json:"options"`
+}
+
+type OllamaResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Message   struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done          bool   `json:"done"`
+	Response      string `json:"response"` // For streaming
+	DoneReason    string `json:"done_reason"`
+	TotalDuration int64  `json:"total_duration"`
+	Error         string `json:"error"`
+}
+
+func (p *OllamaProvider) StreamChat(ctx context.Context, req ChatRequest, onToken func(string) error) (string, error) {
+	ollamaReq := OllamaRequest{
+		Model:    p.Model,
+		Messages: req.Messages,
+		Stream:   true,
+		Format:   req.Format,
+	}
+	ollamaReq.Options.NumCtx = p.NumCtx
+
+	resp, err := postJSON(ctx, p.URL, "", ollamaReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var responseBuilder strings.Builder
+	scanner := newLineScanner(resp.Body)
+	for scanner.Scan() {
+		var chunk OllamaResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			log.Printf("Error parsing Ollama response: %v", err)
+			continue
+		}
+		if chunk.Error != "" {
+			return responseBuilder.String(), fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		// Get content from either field
+		content := chunk.Response
+		if content == "" {
+			content = chunk.Message.Content
+		}
+		if err := emitToken(&responseBuilder, content, onToken); err != nil {
+			return responseBuilder.String(), err
+		}
+
+		if chunk.Done {
+			break
+		}
+	}
+	return responseBuilder.String(), streamErr(ctx, scanner.Err())
+}
+
+// OpenAI-compatible /v1/chat/completions (llama.cpp, vLLM, LM Studio, ...)
+
+type OpenAIProvider struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []ChatMessage         `json:"messages"`
+	Stream         bool                  `json:"stream"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema"`
+}
+
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) StreamChat(ctx context.Context, req ChatRequest, onToken func(string) error) (string, error) {
+	openAIReq := openAIChatRequest{
+		Model:    p.Model,
+		Messages: req.Messages,
+		Stream:   true,
+	}
+	if req.Format != nil {
+		openAIReq.ResponseFormat = &openAIResponseFormat{Type: "json_schema"}
+		openAIReq.ResponseFormat.JSONSchema.Name = "response"
+		openAIReq.ResponseFormat.JSONSchema.Schema = req.Format
+	}
+
+	resp, err := postJSON(ctx, p.URL, p.APIKey, openAIReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The response is a server-sent event stream of "data: {...}" lines
+	// terminated by "data: [DONE]".
+	var responseBuilder strings.Builder
+	scanner := newLineScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("Error parsing OpenAI response: %v", err)
+			continue
+		}
+		if chunk.Error != nil {
+			return responseBuilder.String(), fmt.Errorf("openai error: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if err := emitToken(&responseBuilder, chunk.Choices[0].Delta.Content, onToken); err != nil {
+			return responseBuilder.String(), err
+		}
+	}
+	return responseBuilder.String(), streamErr(ctx, scanner.Err())
+}
+
+// FakeChatProvider answers in-process without a model server. It streams
+// Reply word by word, or echoes the last user message when Reply is empty.
+type FakeChatProvider struct {
+	Reply      string
+	TokenDelay time.Duration
+}
+
+func (p *FakeChatProvider) StreamChat(ctx context.Context, req ChatRequest, onToken func(string) error) (string, error) {
+	reply := p.Reply
+	if reply == "" {
+		lastUserMessage := ""
+		for _, message := range req.Messages {
+			if message.Role == "user" {
+				lastUserMessage = message.Content
+			}
+		}
+		reply = "You said: " + lastUserMessage
+	}
+
+	var responseBuilder strings.Builder
+	for _, word := range strings.SplitAfter(reply, " ") {
+		if p.TokenDelay > 0 {
+			select {
+			case <-time.After(p.TokenDelay):
+			case <-ctx.Done():
+				return responseBuilder.String(), ctx.Err()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return responseBuilder.String(), err
+		}
+		if err := emitToken(&responseBuilder, word, onToken); err != nil {
+			return responseBuilder.String(), err
+		}
+	}
+	return responseBuilder.String(), nil
+}
+
+// JSONObject cuts the JSON object out of a reply, some models wrap it in a
+// markdown code block despite the schema.
+func JSONObject(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start == -1 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
+
+func postJSON(ctx context.Context, url string, apiKey string, payload any) (*http.Response, error) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error [%d]: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scanner
+}
+
+func emitToken(builder *strings.Builder, content string, onToken func(string) error) error {
+	if content == "" {
+		return nil
+	}
+	builder.WriteString(content)
+	if onToken != nil {
+		return onToken(content)
+	}
+	return nil
+}
+
+// streamErr prefers the context error, so callers can tell a cancelled
+// response from a broken connection.
+func streamErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migrations live in core/migrations as NNNN_name.up.sql and
+// NNNN_name.down.sql and are compiled into the binary. Each one runs in its
+// own transaction, so the files must not contain BEGIN/COMMIT.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for pg_advisory_lock, shared by every replica.
+const migrationLockKey = 7254301
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// LoadMigrations returns the embedded migrations ordered by version.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		contents, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// MigrationStatus lists every known migration and whether it was applied.
+func MigrationStatus(db DB) ([]MigrationState, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		at, applied := appliedAt[migration.Version]
+		states[i] = MigrationState{Migration: migration, Applied: applied, AppliedAt: at}
+	}
+	return states, nil
+}
+
+// MigrateUp applies every pending migration in order and returns them. With
+// dryRun nothing is executed and the pending migrations are only returned.
+func MigrateUp(pool *Pool, dryRun bool) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(pool, func(db DB) error {
+		states, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			if state.Applied {
+				continue
+			}
+			if !dryRun {
+				if err := runMigration(db, state.Migration, true); err != nil {
+					return err
+				}
+			}
+			applied = append(applied, state.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first.
+func MigrateDown(pool *Pool, steps int, dryRun bool) ([]Migration, error) {
+	var reverted []Migration
+	err := withMigrationLock(pool, func(db DB) error {
+		states, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+			if !states[i].Applied {
+				continue
+			}
+			if states[i].Down == "" {
+				return fmt.Errorf("migration %04d_%s cannot be reverted, it has no down file", states[i].Version, states[i].Name)
+			}
+			if !dryRun {
+				if err := runMigration(db, states[i].Migration, false); err != nil {
+					return err
+				}
+			}
+			reverted = append(reverted, states[i].Migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func ensureMigrationsTable(db DB) error {
+	_, err := db.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+// withMigrationLock runs f on a dedicated connection holding a session level
+// advisory lock, so two replicas starting together do not migrate at once.
+func withMigrationLock(pool *Pool, f func(db DB) error) error {
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	return f(conn)
+}
+
+func runMigration(db DB, migration Migration, up bool) error {
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	script := migration.Up
+	if !up {
+		script = migration.Down
+	}
+	if _, err := tx.Exec(context.Background(), script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(context.Background(), "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec(context.Background(), "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
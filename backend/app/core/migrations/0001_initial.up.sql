@@ -0,0 +1,46 @@
+-- The tables that used to be created by the database container's init
+-- scripts. IF NOT EXISTS lets databases created that way adopt this migration.
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name TEXT,
+	email TEXT NOT NULL UNIQUE,
+	passwordHash TEXT NOT NULL,
+	sessionToken TEXT NOT NULL,
+	csrfToken TEXT NOT NULL,
+	isAdmin BOOLEAN NOT NULL DEFAULT FALSE,
+	cv TEXT
+);
+
+CREATE TABLE IF NOT EXISTS cv_chunks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chunk TEXT NOT NULL,
+    embedding vector(768) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS cv_chunks_embedding_idx ON cv_chunks USING hnsw (embedding vector_cosine_ops);
+
+CREATE TABLE IF NOT EXISTS conversations (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	title TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS conversations_user_id_updated_at_idx ON conversations (user_id, updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id SERIAL PRIMARY KEY,
+	conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+	role TEXT NOT NULL,
+	content TEXT NOT NULL,
+	cv_context TEXT NOT NULL DEFAULT '',
+	truncated BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_id_id_idx ON messages (conversation_id, id);
@@ -0,0 +1,19 @@
+-- CV uploads are converted and embedded by background workers. file_data is
+-- cleared once a job is done.
+
+CREATE TABLE cv_jobs (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	file_name TEXT NOT NULL,
+	file_data BYTEA,
+	status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'converting', 'embedding', 'done', 'failed')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	run_after TIMESTAMPTZ NOT NULL DEFAULT now(),
+	locked_until TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX cv_jobs_status_run_after_idx ON cv_jobs (status, run_after);
+CREATE INDEX cv_jobs_user_id_id_idx ON cv_jobs (user_id, id DESC);
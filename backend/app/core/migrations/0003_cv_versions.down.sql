@@ -0,0 +1,7 @@
+-- Only the chunks of the active versions are kept.
+DELETE FROM cv_chunks USING cv_versions
+WHERE cv_versions.id = cv_chunks.version_id AND NOT cv_versions.is_active;
+
+ALTER TABLE cv_chunks DROP COLUMN version_id;
+
+DROP TABLE IF EXISTS cv_versions;
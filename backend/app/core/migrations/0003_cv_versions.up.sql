@@ -0,0 +1,33 @@
+-- Every uploaded CV is kept as a version, the active one is used for
+-- retrieval and mirrored in users.cv. Chunks belong to a version, so
+-- restoring an older one does not need new embeddings.
+
+CREATE TABLE cv_versions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	file_name TEXT NOT NULL,
+	original BYTEA NOT NULL,
+	markdown TEXT NOT NULL,
+	uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	is_active BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX cv_versions_user_id_active_idx ON cv_versions (user_id) WHERE is_active;
+CREATE INDEX cv_versions_user_id_id_idx ON cv_versions (user_id, id DESC);
+
+ALTER TABLE cv_chunks ADD COLUMN version_id INTEGER REFERENCES cv_versions(id) ON DELETE CASCADE;
+CREATE INDEX cv_chunks_version_id_idx ON cv_chunks (version_id);
+
+-- CVs uploaded before versioning become the first, active version. Their
+-- original file was not kept, so the markdown stands in for it.
+INSERT INTO cv_versions (user_id, file_name, original, markdown, uploaded_by, is_active)
+SELECT id, 'cv.md', convert_to(cv, 'UTF8'), cv, id, TRUE FROM users WHERE cv IS NOT NULL AND cv <> '';
+
+UPDATE cv_chunks SET version_id = cv_versions.id
+FROM cv_versions
+WHERE cv_versions.user_id = cv_chunks.user_id AND cv_versions.is_active;
+
+DELETE FROM cv_chunks WHERE version_id IS NULL;
+
+ALTER TABLE cv_chunks ALTER COLUMN version_id SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE cv_chunks DROP COLUMN section;
@@ -0,0 +1,2 @@
+-- The CV section a chunk was cut from, empty for text before the first one.
+ALTER TABLE cv_chunks ADD COLUMN section TEXT NOT NULL DEFAULT '';
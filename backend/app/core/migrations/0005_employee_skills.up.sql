@@ -0,0 +1,15 @@
+-- Skills extracted from the active CV, or entered by the employee. Manual
+-- entries survive the extraction of a newly uploaded CV.
+
+CREATE TABLE employee_skills (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	skill TEXT NOT NULL,
+	years_experience NUMERIC(4, 1),
+	last_used_year INTEGER,
+	source TEXT NOT NULL CHECK (source IN ('llm', 'rules', 'manual')),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX employee_skills_user_id_skill_idx ON employee_skills (user_id, lower(skill));
+CREATE INDEX employee_skills_skill_idx ON employee_skills (lower(skill));
@@ -0,0 +1,3 @@
+ALTER TABLE employee_skills DROP COLUMN IF EXISTS skill_id;
+DROP TABLE IF EXISTS skill_aliases;
+DROP TABLE IF EXISTS skills;
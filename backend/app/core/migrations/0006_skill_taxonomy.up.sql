@@ -0,0 +1,97 @@
+-- The skill taxonomy maps the names employees and CVs use for a skill to one
+-- canonical skill. A parent groups skills, e.g. Amazon EKS under Kubernetes,
+-- so a requirement for the parent also finds the children.
+
+CREATE TABLE skills (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	parent_id INTEGER REFERENCES skills(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX skills_name_idx ON skills (lower(name));
+CREATE INDEX skills_parent_id_idx ON skills (parent_id);
+
+CREATE TABLE skill_aliases (
+	id SERIAL PRIMARY KEY,
+	skill_id INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+	alias TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX skill_aliases_alias_idx ON skill_aliases (lower(alias));
+CREATE INDEX skill_aliases_skill_id_idx ON skill_aliases (skill_id);
+
+ALTER TABLE employee_skills ADD COLUMN skill_id INTEGER REFERENCES skills(id) ON DELETE SET NULL;
+CREATE INDEX employee_skills_skill_id_idx ON employee_skills (skill_id);
+
+-- A starting point for the most common synonyms, more come from imports.
+INSERT INTO skills (name) VALUES
+	('Programming languages'),
+	('Databases'),
+	('Cloud platforms'),
+	('Container orchestration');
+
+INSERT INTO skills (name, parent_id) VALUES
+	('JavaScript', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('TypeScript', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('Python', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('Go', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('C#', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('C++', (SELECT id FROM skills WHERE name = 'Programming languages')),
+	('PostgreSQL', (SELECT id FROM skills WHERE name = 'Databases')),
+	('Microsoft SQL Server', (SELECT id FROM skills WHERE name = 'Databases')),
+	('MongoDB', (SELECT id FROM skills WHERE name = 'Databases')),
+	('Amazon Web Services', (SELECT id FROM skills WHERE name = 'Cloud platforms')),
+	('Microsoft Azure', (SELECT id FROM skills WHERE name = 'Cloud platforms')),
+	('Google Cloud Platform', (SELECT id FROM skills WHERE name = 'Cloud platforms')),
+	('Kubernetes', (SELECT id FROM skills WHERE name = 'Container orchestration'));
+
+INSERT INTO skills (name, parent_id) VALUES
+	('Node.js', (SELECT id FROM skills WHERE name = 'JavaScript')),
+	('Amazon EKS', (SELECT id FROM skills WHERE name = 'Kubernetes')),
+	('Azure Kubernetes Service', (SELECT id FROM skills WHERE name = 'Kubernetes')),
+	('Google Kubernetes Engine', (SELECT id FROM skills WHERE name = 'Kubernetes')),
+	('OpenShift', (SELECT id FROM skills WHERE name = 'Kubernetes'));
+
+INSERT INTO skill_aliases (skill_id, alias)
+SELECT skills.id, aliases.alias
+FROM (VALUES
+	('JavaScript', 'JS'),
+	('JavaScript', 'ECMAScript'),
+	('JavaScript', 'ES6'),
+	('TypeScript', 'TS'),
+	('Python', 'Python 3'),
+	('Go', 'Golang'),
+	('C#', 'CSharp'),
+	('C#', 'C Sharp'),
+	('C++', 'CPP'),
+	('PostgreSQL', 'Postgres'),
+	('PostgreSQL', 'Postgre'),
+	('PostgreSQL', 'psql'),
+	('Microsoft SQL Server', 'MSSQL'),
+	('Microsoft SQL Server', 'MS SQL'),
+	('Microsoft SQL Server', 'SQL Server'),
+	('MongoDB', 'Mongo'),
+	('Amazon Web Services', 'AWS'),
+	('Microsoft Azure', 'Azure'),
+	('Google Cloud Platform', 'GCP'),
+	('Google Cloud Platform', 'Google Cloud'),
+	('Kubernetes', 'k8s'),
+	('Kubernetes', 'kube'),
+	('Node.js', 'NodeJS'),
+	('Node.js', 'Node'),
+	('Amazon EKS', 'EKS'),
+	('Amazon EKS', 'AWS EKS'),
+	('Azure Kubernetes Service', 'AKS'),
+	('Google Kubernetes Engine', 'GKE')
+) AS aliases (skill, alias)
+JOIN skills ON skills.name = aliases.skill;
+
+-- Link the skills already stored, by name or alias.
+UPDATE employee_skills SET skill_id = skills.id
+FROM skills
+WHERE lower(skills.name) = lower(employee_skills.skill);
+
+UPDATE employee_skills SET skill_id = skill_aliases.skill_id
+FROM skill_aliases
+WHERE employee_skills.skill_id IS NULL AND lower(skill_aliases.alias) = lower(employee_skills.skill);
@@ -0,0 +1,23 @@
+-- Projects and jobs parsed from the project sections of a CV version. Like
+-- the chunks they belong to a version, so restoring a version restores them.
+
+CREATE TABLE employee_projects (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	version_id INTEGER NOT NULL REFERENCES cv_versions(id) ON DELETE CASCADE,
+	section TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	client TEXT NOT NULL DEFAULT '',
+	role TEXT NOT NULL DEFAULT '',
+	-- Months, stored as their first day. A missing end date with is_current
+	-- means the project is still running.
+	start_date DATE,
+	end_date DATE,
+	is_current BOOLEAN NOT NULL DEFAULT FALSE,
+	team_size INTEGER,
+	technologies TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX employee_projects_version_id_idx ON employee_projects (version_id);
+CREATE INDEX employee_projects_client_idx ON employee_projects (lower(client));
+CREATE INDEX employee_projects_technologies_idx ON employee_projects USING gin (technologies);
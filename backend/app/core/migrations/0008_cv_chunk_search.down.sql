@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS cv_chunks_search_idx;
+ALTER TABLE cv_chunks DROP COLUMN IF EXISTS search;
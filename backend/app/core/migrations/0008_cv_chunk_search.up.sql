@@ -0,0 +1,6 @@
+-- Full-text search over the chunks, next to the vector search. The simple
+-- configuration neither stems nor drops words, CVs mix languages and exact
+-- tokens like "AZ-104" matter more than word forms.
+ALTER TABLE cv_chunks ADD COLUMN search tsvector GENERATED ALWAYS AS (to_tsvector('simple', chunk)) STORED;
+
+CREATE INDEX cv_chunks_search_idx ON cv_chunks USING gin (search);
@@ -0,0 +1,3 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS retrieval_mmr_lambda;
+ALTER TABLE conversations DROP COLUMN IF EXISTS retrieval_min_similarity;
+ALTER TABLE conversations DROP COLUMN IF EXISTS retrieval_top_k;
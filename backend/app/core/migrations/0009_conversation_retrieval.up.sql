@@ -0,0 +1,5 @@
+-- Retrieval settings chosen for a single conversation, NULL keeps the
+-- deployment default.
+ALTER TABLE conversations ADD COLUMN retrieval_top_k INTEGER;
+ALTER TABLE conversations ADD COLUMN retrieval_min_similarity DOUBLE PRECISION;
+ALTER TABLE conversations ADD COLUMN retrieval_mmr_lambda DOUBLE PRECISION;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_location_idx;
+DROP INDEX IF EXISTS users_department_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS employment_status;
+ALTER TABLE users DROP COLUMN IF EXISTS seniority;
+ALTER TABLE users DROP COLUMN IF EXISTS location;
+ALTER TABLE users DROP COLUMN IF EXISTS department;
@@ -0,0 +1,11 @@
+-- Attributes retrieval can be filtered by. Department and location are free
+-- text maintained by the administrators, everyone starts as active.
+ALTER TABLE users ADD COLUMN department TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN location TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN seniority TEXT NOT NULL DEFAULT ''
+	CHECK (seniority IN ('', 'intern', 'junior', 'mid', 'senior', 'lead', 'principal'));
+ALTER TABLE users ADD COLUMN employment_status TEXT NOT NULL DEFAULT 'active'
+	CHECK (employment_status IN ('active', 'on_leave', 'left'));
+
+CREATE INDEX users_department_idx ON users (lower(department));
+CREATE INDEX users_location_idx ON users (lower(location));
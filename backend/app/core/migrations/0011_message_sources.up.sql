@@ -0,0 +1,3 @@
+-- The CV chunks an answer was based on, so its [n] citations still open the
+-- right CV section when the conversation is reopened.
+ALTER TABLE messages ADD COLUMN sources JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN IF EXISTS summary_until;
+ALTER TABLE conversations DROP COLUMN IF EXISTS summary;
@@ -0,0 +1,4 @@
+-- Running summary of the messages that no longer fit in the context window
+-- of the chat model, up to and including the message summary_until.
+ALTER TABLE conversations ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN summary_until INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"time"
+)
+
+// EmployeeProject is a project or job parsed from a CV. Fields the CV does
+// not state are empty or nil.
+type EmployeeProject struct {
+	Id        int
+	UserId    int
+	VersionId int
+	// Section is the CV section the project was found in.
+	Section string
+	Name    string
+	Client  string
+	Role    string
+	// StartDate and EndDate are the first day of their month.
+	StartDate    *time.Time
+	EndDate      *time.Time
+	IsCurrent    bool
+	TeamSize     *int
+	Technologies []string
+
+	// Set when listing projects across employees.
+	EmployeeName string
+}
+
+// ProjectFilter narrows a project search, zero values match everything.
+type ProjectFilter struct {
+	Client     string
+	Technology string
+	Role       string
+	// WithinYears keeps projects running at some point in the last years.
+	WithinYears int
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Reranker scores how well each document answers a query, one score per
+// document in the same order, higher is better.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// NewReranker builds the reranker selected by the RERANK_PROVIDER env
+// variable: "" or "none" for no reranking, "llm" to let the chat model score
+// the chunks, "api" for a cross-encoder behind a /v1/rerank endpoint, or
+// "fake". It returns nil when reranking is off.
+func NewReranker(llm ChatProvider) (Reranker, error) {
+	switch provider := os.Getenv("RERANK_PROVIDER"); provider {
+	case "", "none":
+		return nil, nil
+	case "llm":
+		return &LLMReranker{LLM: llm}, nil
+	case "api":
+		return &APIReranker{
+			URL:    EnvOrDefault("RERANK_API", "http://localhost:8000/v1/rerank"),
+			Model:  os.Getenv("RERANK_MODEL"),
+			APIKey: os.Getenv("OPENAI_API_KEY"),
+		}, nil
+	case "fake":
+		return &FakeReranker{}, nil
+	default:
+		return nil, fmt.Errorf("unknown RERANK_PROVIDER %q", provider)
+	}
+}
+
+// Reranking reorders the best retrieved chunks with a reranker. Slow or
+// failing reranks keep the retrieval order, so the answer is never held up
+// by more than Budget.
+type Reranking struct {
+	Reranker Reranker
+	// Candidates is how many of the best chunks get reranked.
+	Candidates int
+	Budget     time.Duration
+	cache      *rerankCache
+}
+
+// NewReranking wraps a reranker with the RERANK_* settings, nil when the
+// reranker is nil.
+func NewReranking(reranker Reranker) *Reranking {
+	if reranker == nil {
+		return nil
+	}
+	return &Reranking{
+		Reranker:   reranker,
+		Candidates: max(EnvInt("RERANK_CANDIDATES", 20), 1),
+		Budget:     EnvDuration("RERANK_BUDGET", 5*time.Second),
+		cache:      newRerankCache(max(EnvInt("RERANK_CACHE_SIZE", 10000), 0)),
+	}
+}
+
+// Apply reranks the first Candidates chunks, which then come first ordered by
+// their new score. The other chunks follow in their old order with a score of
+// 0, so they no longer outweigh reranked ones.
+func (r *Reranking) Apply(query string, chunks []CVChunk) []CVChunk {
+	count := min(r.Candidates, len(chunks))
+	if count == 0 {
+		return chunks
+	}
+
+	scores := make([]float64, count)
+	var missing []int
+	for i, chunk := range chunks[:count] {
+		if score, ok := r.cache.get(query, chunk.Id); ok {
+			scores[i] = score
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) > 0 {
+		documents := make([]string, len(missing))
+		for j, i := range missing {
+			documents[j] = chunks[i].Chunk
+		}
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), r.Budget)
+		defer cancel()
+		rerankScores, err := r.Reranker.Rerank(ctx, query, documents)
+		if err == nil && len(rerankScores) != len(documents) {
+			err = fmt.Errorf("expected %d scores, got %d", len(documents), len(rerankScores))
+		}
+		if err != nil {
+			log.Printf("Reranking failed after %s, keeping the retrieval order: %v", time.Since(start).Round(time.Millisecond), err)
+			return chunks
+		}
+
+		for j, i := range missing {
+			scores[i] = rerankScores[j]
+			r.cache.put(query, chunks[i].Id, rerankScores[j])
+		}
+	}
+
+	reranked := make([]CVChunk, 0, len(chunks))
+	for i, chunk := range chunks[:count] {
+		chunk.Score = scores[i]
+		reranked = append(reranked, chunk)
+	}
+	// Stable, so equal scores keep the retrieval order.
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	for _, chunk := range chunks[count:] {
+		chunk.Score = 0
+		reranked = append(reranked, chunk)
+	}
+	return reranked
+}
+
+// rerankCache keeps the scores of recent query and chunk pairs, dropping the
+// oldest when full. Chunks never change, a new CV version gets new ones.
+type rerankCache struct {
+	mu     sync.Mutex
+	size   int
+	scores map[string]float64
+	order  []string
+}
+
+func newRerankCache(size int) *rerankCache {
+	return &rerankCache{size: size, scores: map[string]float64{}}
+}
+
+func rerankCacheKey(query string, chunkId int) string {
+	return strconv.Itoa(chunkId) + "\x00" + query
+}
+
+func (c *rerankCache) get(query string, chunkId int) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	score, ok := c.scores[rerankCacheKey(query, chunkId)]
+	return score, ok
+}
+
+func (c *rerankCache) put(query string, chunkId int, score float64) {
+	if c.size == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := rerankCacheKey(query, chunkId)
+	if _, ok := c.scores[key]; !ok {
+		if len(c.order) == c.size {
+			delete(c.scores, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.scores[key] = score
+}
+
+const rerankSchema = `{
+	"type": "object",
+	"properties": {
+		"scores": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "integer"},
+					"score": {"type": "number"}
+				},
+				"required": ["id", "score"]
+			}
+		}
+	},
+	"required": ["scores"]
+}`
+
+const rerankPrompt = `You grade CV excerpts for a question about staffing a team.
+Give every excerpt a score from 0 to 10: 10 when it shows exactly the experience asked for, for example a project using the requested technology, 0 when it is unrelated.
+Excerpts only listing spoken languages, contact details or hobbies score 0 unless the question asks for them.
+Reply with the id and score of every excerpt.`
+
+// Long chunks are cut for grading, the beginning tells what they are about.
+const maxRerankDocumentChars = 1500
+
+// LLMReranker lets the chat model grade all documents in a single request.
+type LLMReranker struct {
+	LLM ChatProvider
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	var content strings.Builder
+	content.WriteString("Question: " + query + "\n\nExcerpts:\n")
+	for i, document := range documents {
+		if runes := []rune(document); len(runes) > maxRerankDocumentChars {
+			document = string(runes[:maxRerankDocumentChars]) + "..."
+		}
+		fmt.Fprintf(&content, "\n[%d]\n%s\n", i+1, document)
+	}
+
+	reply, err := r.LLM.StreamChat(ctx, ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: rerankPrompt},
+			{Role: "user", Content: content.String()},
+		},
+		Format: json.RawMessage(rerankSchema),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Scores []struct {
+			Id    int     `json:"id"`
+			Score float64 `json:"score"`
+		} `json:"scores"`
+	}
+	if err := json.Unmarshal([]byte(JSONObject(reply)), &result); err != nil {
+		return nil, fmt.Errorf("invalid rerank JSON: %w", err)
+	}
+
+	// Excerpts the model skipped score 0.
+	scores := make([]float64, len(documents))
+	for _, score := range result.Scores {
+		if score.Id >= 1 && score.Id <= len(documents) {
+			scores[score.Id-1] = min(max(score.Score, 0), 10) / 10
+		}
+	}
+	return scores, nil
+}
+
+// APIReranker calls a cross-encoder behind a /v1/rerank endpoint, as served
+// by llama.cpp, vLLM, Infinity or TEI compatible proxies.
+type APIReranker struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+func (r *APIReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	var rerankResp struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	payload := map[string]any{
+		"model":     r.Model,
+		"query":     query,
+		"documents": documents,
+	}
+	if err := postJSONAndDecode(ctx, r.URL, r.APIKey, payload, &rerankResp); err != nil {
+		return nil, err
+	}
+
+	if len(rerankResp.Results) != len(documents) {
+		return nil, fmt.Errorf("expected %d rerank results, got %d", len(documents), len(rerankResp.Results))
+	}
+	scores := make([]float64, len(documents))
+	for _, result := range rerankResp.Results {
+		if result.Index < 0 || result.Index >= len(scores) {
+			return nil, fmt.Errorf("rerank index %d out of range", result.Index)
+		}
+		scores[result.Index] = result.RelevanceScore
+	}
+	return scores, nil
+}
+
+// FakeReranker scores documents by the share of query words they contain,
+// enough to exercise reranking without a model server.
+type FakeReranker struct{}
+
+func (r *FakeReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	words := func(text string) map[string]bool {
+		return toSet(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	queryWords := words(query)
+	scores := make([]float64, len(documents))
+	for i, document := range documents {
+		if len(queryWords) == 0 {
+			continue
+		}
+		documentWords := words(document)
+		found := 0
+		for word := range queryWords {
+			if documentWords[word] {
+				found++
+			}
+		}
+		scores[i] = float64(found) / float64(len(queryWords))
+	}
+	return scores, nil
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Retrieval modes.
+const (
+	// RetrievalChunks returns the best chunks, no matter whose.
+	RetrievalChunks = "chunks"
+	// RetrievalEmployees returns the best employees with their best chunks,
+	// so one CV cannot take all the places.
+	RetrievalEmployees = "employees"
+)
+
+// RetrievalConfig tunes how CV chunks are found for a question. Vector and
+// full-text search each rank their candidates, and the ranks are fused with
+// reciprocal rank fusion: score = w / (k + rank), summed over both lists.
+type RetrievalConfig struct {
+	// Mode is RetrievalChunks or RetrievalEmployees.
+	Mode string
+	// TopK is how many results reach the model, chunks or employees
+	// depending on the mode.
+	TopK              int
+	ChunksPerEmployee int
+	// MinSimilarity drops the vector matches whose embedding is farther from
+	// the question, so small talk gets no CV context at all. Full-text
+	// matches are kept, exact terms like "AZ-104" embed poorly.
+	MinSimilarity float64
+	// MMRLambda below 1 diversifies the chunks by maximal marginal relevance,
+	// lower values favour chunks unlike the ones already picked.
+	MMRLambda float64
+	// VectorWeight and KeywordWeight scale the two rankings, 0 turns one off.
+	VectorWeight  float64
+	KeywordWeight float64
+	// RRFK damps the difference between the top ranks, 60 is the usual value.
+	RRFK int
+	// Candidates is how many chunks each search ranks before fusing.
+	Candidates int
+	// Filter restricts the employees searched, the default leaves out the
+	// administrators and the people who left.
+	Filter EmployeeFilter
+	// Rerank reorders the best chunks before they are picked, nil when off.
+	Rerank *Reranking
+}
+
+func NewRetrievalConfig() RetrievalConfig {
+	mode := EnvOrDefault("RETRIEVAL_MODE", RetrievalEmployees)
+	if mode != RetrievalChunks && mode != RetrievalEmployees {
+		log.Printf("Unknown RETRIEVAL_MODE %q, using %q.", mode, RetrievalEmployees)
+		mode = RetrievalEmployees
+	}
+
+	return RetrievalConfig{
+		Mode:              mode,
+		TopK:              max(EnvInt("RETRIEVAL_TOP_K", 5), 1),
+		ChunksPerEmployee: max(EnvInt("RETRIEVAL_CHUNKS_PER_EMPLOYEE", 2), 1),
+		MinSimilarity:     EnvFloat("RETRIEVAL_MIN_SIMILARITY", 0.4),
+		MMRLambda:         min(max(EnvFloat("RETRIEVAL_MMR_LAMBDA", 1), 0), 1),
+		Filter:            DefaultEmployeeFilter(),
+		VectorWeight:      max(EnvFloat("RETRIEVAL_VECTOR_WEIGHT", 1), 0),
+		KeywordWeight:     max(EnvFloat("RETRIEVAL_KEYWORD_WEIGHT", 1), 0),
+		RRFK:              max(EnvInt("RETRIEVAL_RRF_K", 60), 1),
+		Candidates:        max(EnvInt("RETRIEVAL_CANDIDATES", 50), 1),
+	}
+}
+
+// RetrievalOverride holds the retrieval settings of a conversation, nil
+// fields keep the deployment defaults.
+type RetrievalOverride struct {
+	TopK          *int
+	MinSimilarity *float64
+	MMRLambda     *float64
+}
+
+// With returns the config with the set fields of the override applied.
+func (config RetrievalConfig) With(override RetrievalOverride) RetrievalConfig {
+	if override.TopK != nil {
+		config.TopK = *override.TopK
+	}
+	if override.MinSimilarity != nil {
+		config.MinSimilarity = *override.MinSimilarity
+	}
+	if override.MMRLambda != nil {
+		config.MMRLambda = *override.MMRLambda
+	}
+	return config
+}
+
+// Words too common in questions and CVs to help a full-text search, in the
+// languages of the CVs.
+var keywordStopWords = toSet(
+	// English
+	"a", "about", "all", "also", "an", "and", "any", "are", "as", "at", "be", "been", "but", "by", "can", "could",
+	"do", "does", "for", "from", "has", "have", "he", "her", "his", "how", "i", "in", "into", "is", "it", "its",
+	"know", "knows", "me", "more", "most", "my", "need", "needs", "of", "on", "one", "or", "our", "she", "should",
+	"some", "someone", "somebody", "than", "that", "the", "their", "them", "there", "these", "they", "this", "to",
+	"us", "was", "we", "were", "what", "when", "where", "which", "who", "whom", "why", "will", "with", "would",
+	"you", "your", "experience", "experienced", "team", "project", "projects", "people", "employee", "employees",
+	"find", "give", "list", "show", "good", "best", "worked", "work", "working", "years", "year",
+	// German
+	"der", "die", "das", "den", "dem", "des", "ein", "eine", "einen", "einem", "und", "oder", "mit", "von", "für",
+	"ist", "sind", "hat", "haben", "wer", "welche", "welcher", "wie", "was", "im", "in", "zu", "auf", "bei", "nach",
+	"erfahrung", "jemand", "mitarbeiter",
+	// Bulgarian
+	"и", "или", "на", "в", "във", "с", "със", "за", "от", "по", "до", "кой", "коя", "кои", "който", "която", "които",
+	"има", "е", "са", "да", "се", "опит", "служител", "служители", "екип", "проект",
+)
+
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// KeywordQuery turns a question into a to_tsquery expression matching any of
+// its informative words, or "" when none is left. Words written with hyphens
+// like "AZ-104" must match as a phrase, the parser indexes their parts too.
+// Dotted words like "ASP.NET" or "node.js" are usually indexed as a single
+// lexeme, so they match either that or the phrase of their parts.
+func KeywordQuery(text string) string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		// Only letters, digits and the dots of a dotted word reach the
+		// query, nothing needs escaping.
+		isSeparator := func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}
+		parts := strings.FieldsFunc(word, isSeparator)
+		if len(parts) == 0 {
+			continue
+		}
+
+		term := strings.Join(parts, " <-> ")
+		if len(parts) == 1 {
+			if len([]rune(term)) < 2 || keywordStopWords[term] {
+				continue
+			}
+		} else if dotted := strings.Join(parts, "."); dotted == strings.TrimFunc(word, isSeparator) {
+			term = "(" + dotted + " | (" + term + "))"
+		} else {
+			term = "(" + term + ")"
+		}
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return strings.Join(terms, " | ")
+}
+
+// EmployeeMatch is an employee found for a question with the chunks that
+// support the match, best first.
+type EmployeeMatch struct {
+	UserId int
+	Name   string
+	Score  float64
+	Chunks []CVChunk
+}
+
+// SelectCVChunks returns the TopK chunks for a question, reranked when
+// configured and diversified when MMRLambda is below 1.
+func SelectCVChunks(db DB, queryEmbedding []float32, queryText string, config RetrievalConfig) ([]CVChunk, error) {
+	limit := config.TopK
+	if config.MMRLambda < 1 {
+		// Diversifying needs more candidates than it picks.
+		limit = 2 * config.Candidates
+	} else if config.Rerank != nil {
+		limit = max(limit, config.Rerank.Candidates)
+	}
+	chunks, err := GetRelevantCVChunks(db, queryEmbedding, queryText, limit, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Rerank != nil {
+		chunks = config.Rerank.Apply(queryText, chunks)
+	}
+	return diversify(chunks, config.TopK, config.MMRLambda), nil
+}
+
+// GetRelevantEmployees ranks the employees owning the fused chunk candidates.
+// An employee's score adds up their best chunks with falling weights, 1, 1/2,
+// 1/3 and so on, so the best chunk counts most but a second good one beats a
+// single lucky hit.
+func GetRelevantEmployees(db DB, queryEmbedding []float32, queryText string, config RetrievalConfig) ([]EmployeeMatch, error) {
+	// Every candidate of both searches, the fusion never returns more.
+	chunks, err := GetRelevantCVChunks(db, queryEmbedding, queryText, 2*config.Candidates, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Rerank != nil {
+		chunks = config.Rerank.Apply(queryText, chunks)
+	}
+
+	positions := map[int]int{}
+	var employees []EmployeeMatch
+	for _, chunk := range chunks {
+		i, ok := positions[chunk.UserId]
+		if !ok {
+			i = len(employees)
+			positions[chunk.UserId] = i
+			employees = append(employees, EmployeeMatch{UserId: chunk.UserId, Name: chunk.Name})
+		}
+		employees[i].Chunks = append(employees[i].Chunks, chunk)
+	}
+
+	for i := range employees {
+		employees[i].Chunks = diversify(employees[i].Chunks, config.ChunksPerEmployee, config.MMRLambda)
+		for j, chunk := range employees[i].Chunks {
+			employees[i].Score += chunk.Score / float64(j+1)
+		}
+	}
+
+	sort.SliceStable(employees, func(i, j int) bool {
+		return employees[i].Score > employees[j].Score
+	})
+	if len(employees) > config.TopK {
+		employees = employees[:config.TopK]
+	}
+	return employees, nil
+}
+
+// diversify picks k of the chunks, sorted best first, by maximal marginal
+// relevance: every pick weighs the score of a chunk against its similarity to
+// the chunks picked before. With lambda 1 that is just the first k.
+func diversify(chunks []CVChunk, k int, lambda float64) []CVChunk {
+	if lambda >= 1 || len(chunks) <= k {
+		return chunks[:min(k, len(chunks))]
+	}
+
+	// Scores are fused ranks, scaled to the range of the similarities.
+	top := chunks[0].Score
+	if top <= 0 {
+		top = 1
+	}
+
+	remaining := slices.Clone(chunks)
+	picked := make([]CVChunk, 0, k)
+	for len(picked) < k && len(remaining) > 0 {
+		best, bestValue := 0, math.Inf(-1)
+		for i, chunk := range remaining {
+			redundancy := 0.0
+			for _, other := range picked {
+				redundancy = max(redundancy, cosineSimilarity(chunk.Embedding, other.Embedding))
+			}
+			value := lambda*chunk.Score/top - (1-lambda)*redundancy
+			if value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		picked = append(picked, remaining[best])
+		remaining = slices.Delete(remaining, best, best+1)
+	}
+	return picked
+}
+
+func cosineSimilarity(a []float32, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
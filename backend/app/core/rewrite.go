@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const rewriteSchema = `{
+	"type": "object",
+	"properties": {
+		"query": {"type": "string"}
+	},
+	"required": ["query"]
+}`
+
+const rewritePrompt = `You turn the last message of a conversation about staffing a project into a standalone search query for a database of employee CVs.
+Keep every requirement still valid from the earlier messages: technologies, skills, roles, seniority, location, industry and project details. Apply the changes the last message asks for, e.g. "more senior" or "without Java".
+Write the query in the language of the last message, as a short description of the wanted employee. Do not answer the question.`
+
+// Earlier messages are cut to this length for rewriting, the requirements
+// are usually stated in the first sentences.
+const maxRewriteMessageChars = 1000
+
+// QueryRewriter condenses a follow-up question and the conversation before
+// it into a standalone search query, so "what about someone more senior?"
+// still searches for the project discussed before.
+type QueryRewriter struct {
+	LLM     ChatProvider
+	Timeout time.Duration
+	// MaxMessages is how many earlier messages the rewrite sees.
+	MaxMessages int
+}
+
+// NewQueryRewriter returns nil when QUERY_REWRITE is "false".
+func NewQueryRewriter(llm ChatProvider) *QueryRewriter {
+	if EnvOrDefault("QUERY_REWRITE", "true") == "false" {
+		return nil
+	}
+	return &QueryRewriter{
+		LLM:         llm,
+		Timeout:     EnvDuration("QUERY_REWRITE_TIMEOUT", 20*time.Second),
+		MaxMessages: max(EnvInt("QUERY_REWRITE_MESSAGES", 6), 1),
+	}
+}
+
+// Rewrite returns the search query for message given the summary of the old
+// messages and the earlier messages of the conversation. The first question
+// is used as it is, and so is the message when the model fails.
+func (r *QueryRewriter) Rewrite(ctx context.Context, summary string, history []ChatMessage, message string) string {
+	var earlier []ChatMessage
+	for _, turn := range history {
+		if turn.Role == "user" || turn.Role == "assistant" {
+			earlier = append(earlier, turn)
+		}
+	}
+	if len(earlier) == 0 && summary == "" {
+		return message
+	}
+	earlier = earlier[max(len(earlier)-r.MaxMessages, 0):]
+
+	var content strings.Builder
+	if summary != "" {
+		content.WriteString("Summary of the older messages:\n" + summary + "\n\n")
+	}
+	content.WriteString("Conversation:\n")
+	for _, turn := range earlier {
+		text := turn.Content
+		if runes := []rune(text); len(runes) > maxRewriteMessageChars {
+			text = string(runes[:maxRewriteMessageChars]) + "..."
+		}
+		fmt.Fprintf(&content, "\n%s: %s\n", turn.Role, text)
+	}
+	content.WriteString("\nLast message: " + message)
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	reply, err := r.LLM.StreamChat(ctx, ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: rewritePrompt},
+			{Role: "user", Content: content.String()},
+		},
+		Format: json.RawMessage(rewriteSchema),
+	}, nil)
+	if err != nil {
+		log.Printf("Query rewriting failed, searching for the message as it is: %v", err)
+		return message
+	}
+
+	var result struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(JSONObject(reply)), &result); err != nil {
+		log.Printf("Invalid query rewriting JSON, searching for the message as it is: %v", err)
+		return message
+	}
+	if query := strings.TrimSpace(result.Query); query != "" {
+		return query
+	}
+	return message
+}
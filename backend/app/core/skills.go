@@ -0,0 +1,33 @@
+package core
+
+import (
+	"strings"
+	"time"
+)
+
+// Where an employee skill comes from.
+const (
+	SkillSourceLLM    = "llm"
+	SkillSourceRules  = "rules"
+	SkillSourceManual = "manual"
+)
+
+type EmployeeSkill struct {
+	Id     int
+	UserId int
+	Skill  string
+	// SkillId links the skill to the taxonomy, nil when it is not known there.
+	SkillId *int
+	// YearsExperience and LastUsedYear are nil when unknown.
+	YearsExperience *float64
+	LastUsedYear    *int
+	Source          string
+	UpdatedAt       time.Time
+}
+
+// NormalizeSkillName tidies a skill name for storing, skills are compared
+// case-insensitively.
+func NormalizeSkillName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, " .,;:-–•*")
+}
@@ -0,0 +1,445 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Skill is a canonical skill of the taxonomy. Employee skills written as the
+// name or one of the aliases are linked to it.
+type Skill struct {
+	Id       int
+	Name     string
+	ParentId *int
+	Aliases  []string
+}
+
+// SkillTaxonomy resolves skill names and aliases to canonical skills and
+// knows their hierarchy. It is a snapshot, load it again after changes.
+type SkillTaxonomy struct {
+	skills   map[int]*Skill
+	index    map[string]int
+	exact    map[string]bool
+	children map[int][]int
+	maxWords int
+}
+
+// Names this short, like "Go" or "JS", are only found in free text when
+// written exactly like in the taxonomy, "go" is too common a word.
+const maxCaseInsensitiveRunes = 2
+
+func NewSkillTaxonomy(skills []Skill) *SkillTaxonomy {
+	t := &SkillTaxonomy{
+		skills:   map[int]*Skill{},
+		index:    map[string]int{},
+		exact:    map[string]bool{},
+		children: map[int][]int{},
+	}
+	for i := range skills {
+		skill := skills[i]
+		t.skills[skill.Id] = &skill
+		if skill.ParentId != nil {
+			t.children[*skill.ParentId] = append(t.children[*skill.ParentId], skill.Id)
+		}
+	}
+	// Names win over aliases of other skills.
+	for _, skill := range skills {
+		t.add(skill.Name, skill.Id)
+	}
+	for _, skill := range skills {
+		for _, alias := range skill.Aliases {
+			t.add(alias, skill.Id)
+		}
+	}
+	return t
+}
+
+func (t *SkillTaxonomy) add(term string, skillId int) {
+	term = NormalizeSkillName(term)
+	key := strings.ToLower(term)
+	if key == "" {
+		return
+	}
+	if _, ok := t.index[key]; !ok {
+		t.index[key] = skillId
+	}
+	t.exact[term] = true
+	t.maxWords = max(t.maxWords, len(strings.Fields(term)))
+}
+
+// LoadSkillTaxonomy reads the whole taxonomy, it is small enough to keep in
+// memory while resolving a CV or a question.
+func LoadSkillTaxonomy(db DB) (*SkillTaxonomy, error) {
+	rows, err := db.Query(context.Background(), "SELECT id, name, parent_id FROM skills")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skills []Skill
+	positions := map[int]int{}
+	for rows.Next() {
+		var skill Skill
+		if err := rows.Scan(&skill.Id, &skill.Name, &skill.ParentId); err != nil {
+			return nil, err
+		}
+		positions[skill.Id] = len(skills)
+		skills = append(skills, skill)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	aliasRows, err := db.Query(context.Background(), "SELECT skill_id, alias FROM skill_aliases ORDER BY lower(alias)")
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var skillId int
+		var alias string
+		if err := aliasRows.Scan(&skillId, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := positions[skillId]; ok {
+			skills[i].Aliases = append(skills[i].Aliases, alias)
+		}
+	}
+	if err := aliasRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewSkillTaxonomy(skills), nil
+}
+
+// Skills returns all skills ordered by name.
+func (t *SkillTaxonomy) Skills() []Skill {
+	skills := make([]Skill, 0, len(t.skills))
+	for _, skill := range t.skills {
+		skills = append(skills, *skill)
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		return strings.ToLower(skills[i].Name) < strings.ToLower(skills[j].Name)
+	})
+	return skills
+}
+
+func (t *SkillTaxonomy) Get(skillId int) (Skill, bool) {
+	skill, ok := t.skills[skillId]
+	if !ok {
+		return Skill{}, false
+	}
+	return *skill, true
+}
+
+// Resolve finds the canonical skill of a name or alias, ignoring case.
+func (t *SkillTaxonomy) Resolve(name string) (Skill, bool) {
+	skillId, ok := t.index[strings.ToLower(NormalizeSkillName(name))]
+	if !ok {
+		return Skill{}, false
+	}
+	return t.Get(skillId)
+}
+
+// Canonical returns the canonical name and id of a skill, or the normalized
+// name and nil when the taxonomy does not know it.
+func (t *SkillTaxonomy) Canonical(name string) (string, *int) {
+	if skill, ok := t.Resolve(name); ok {
+		return skill.Name, &skill.Id
+	}
+	return NormalizeSkillName(name), nil
+}
+
+// Descendants returns the skill and everything below it in the hierarchy.
+func (t *SkillTaxonomy) Descendants(skillId int) []Skill {
+	var descendants []Skill
+	seen := map[int]bool{}
+	queue := []int{skillId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if skill, ok := t.skills[id]; ok {
+			descendants = append(descendants, *skill)
+		}
+		queue = append(queue, t.children[id]...)
+	}
+	return descendants
+}
+
+// Validate reports a skill that is its own ancestor, and aliases that are
+// the name of another skill and would never be used.
+func (t *SkillTaxonomy) Validate() error {
+	for id, skill := range t.skills {
+		for _, alias := range skill.Aliases {
+			if other, ok := t.index[strings.ToLower(NormalizeSkillName(alias))]; ok && other != id {
+				return fmt.Errorf("alias %q of %q is the skill %q, merge the two skills instead", alias, skill.Name, t.skills[other].Name)
+			}
+		}
+
+		seen := map[int]bool{id: true}
+		for parent := skill.ParentId; parent != nil; {
+			if seen[*parent] {
+				return fmt.Errorf("skill %q is part of a parent cycle", skill.Name)
+			}
+			seen[*parent] = true
+			next, ok := t.skills[*parent]
+			if !ok {
+				break
+			}
+			parent = next.ParentId
+		}
+	}
+	return nil
+}
+
+// FindInText returns the skills mentioned in free text like a team
+// requirement, preferring the longest match, e.g. "SQL Server" over "SQL".
+func (t *SkillTaxonomy) FindInText(text string) []Skill {
+	var words []string
+	for _, word := range strings.Fields(strings.NewReplacer("/", " ", "\n", " ").Replace(text)) {
+		word = strings.TrimLeft(word, `"'([{`)
+		word = strings.TrimRight(word, `"'.,;:!?)]}`)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	var found []Skill
+	seen := map[int]bool{}
+	for i := 0; i < len(words); i++ {
+		for n := min(t.maxWords, len(words)-i); n > 0; n-- {
+			phrase := strings.Join(words[i:i+n], " ")
+			skillId, ok := t.index[strings.ToLower(phrase)]
+			if !ok || (len([]rune(phrase)) <= maxCaseInsensitiveRunes && !t.exact[phrase]) {
+				continue
+			}
+			if !seen[skillId] {
+				seen[skillId] = true
+				found = append(found, *t.skills[skillId])
+			}
+			i += n - 1
+			break
+		}
+	}
+	return found
+}
+
+// LinkEmployeeSkills applies the taxonomy to all stored employee skills:
+// names are replaced by the canonical name and linked to the skill, and
+// entries that now name the same skill are merged. A manual entry wins,
+// missing numbers are taken from the merged ones.
+func LinkEmployeeSkills(db DB) error {
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	taxonomy, err := LoadSkillTaxonomy(tx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(
+		context.Background(),
+		"SELECT id, user_id, skill, skill_id, years_experience::float8, last_used_year, source FROM employee_skills ORDER BY id")
+	if err != nil {
+		return err
+	}
+	type group struct {
+		keep   EmployeeSkill
+		others []EmployeeSkill
+	}
+	groups := map[[2]int]*group{}
+	var order [][2]int
+	var unlinked []EmployeeSkill
+	for rows.Next() {
+		var skill EmployeeSkill
+		if err := rows.Scan(&skill.Id, &skill.UserId, &skill.Skill, &skill.SkillId, &skill.YearsExperience, &skill.LastUsedYear, &skill.Source); err != nil {
+			rows.Close()
+			return err
+		}
+		canonical, ok := taxonomy.Resolve(skill.Skill)
+		if !ok {
+			if skill.SkillId != nil {
+				unlinked = append(unlinked, skill)
+			}
+			continue
+		}
+
+		key := [2]int{skill.UserId, canonical.Id}
+		g, ok := groups[key]
+		if !ok {
+			groups[key] = &group{keep: skill}
+			order = append(order, key)
+			continue
+		}
+		if preferSkill(skill, g.keep, canonical.Name) {
+			g.keep, skill = skill, g.keep
+		}
+		g.others = append(g.others, skill)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, skill := range unlinked {
+		if _, err := tx.Exec(context.Background(), "UPDATE employee_skills SET skill_id = NULL WHERE id = $1", skill.Id); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range order {
+		g := groups[key]
+		canonical, _ := taxonomy.Get(key[1])
+		keep := g.keep
+		changed := keep.Skill != canonical.Name || keep.SkillId == nil || *keep.SkillId != canonical.Id
+
+		for _, other := range g.others {
+			// Numbers the employee entered are not overwritten.
+			if other.YearsExperience != nil && (keep.YearsExperience == nil || (keep.Source != SkillSourceManual && *other.YearsExperience > *keep.YearsExperience)) {
+				keep.YearsExperience = other.YearsExperience
+				changed = true
+			}
+			if other.LastUsedYear != nil && (keep.LastUsedYear == nil || (keep.Source != SkillSourceManual && *other.LastUsedYear > *keep.LastUsedYear)) {
+				keep.LastUsedYear = other.LastUsedYear
+				changed = true
+			}
+			if _, err := tx.Exec(context.Background(), "DELETE FROM employee_skills WHERE id = $1", other.Id); err != nil {
+				return err
+			}
+		}
+
+		if changed {
+			_, err := tx.Exec(
+				context.Background(),
+				"UPDATE employee_skills SET skill = $1, skill_id = $2, years_experience = $3, last_used_year = $4 WHERE id = $5",
+				canonical.Name, canonical.Id, keep.YearsExperience, keep.LastUsedYear, keep.Id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// preferSkill reports whether a should be kept over b when both name the
+// same skill.
+func preferSkill(a EmployeeSkill, b EmployeeSkill, canonicalName string) bool {
+	if (a.Source == SkillSourceManual) != (b.Source == SkillSourceManual) {
+		return a.Source == SkillSourceManual
+	}
+	return a.Skill == canonicalName && b.Skill != canonicalName
+}
+
+// SkillUsage is a skill of the taxonomy with the number of employees having it.
+type SkillUsage struct {
+	Skill      Skill
+	ParentName string
+	Employees  int
+}
+
+// UnlinkedSkill is an employee skill name the taxonomy does not know.
+type UnlinkedSkill struct {
+	Name      string
+	Employees int
+}
+
+// SkilledEmployee is an employee having a skill of a requirement.
+type SkilledEmployee struct {
+	UserId          int
+	Name            string
+	Skill           string
+	YearsExperience *float64
+	LastUsedYear    *int
+}
+
+// SkillRequirement is a skill asked for in a question together with the
+// employees having it or one of the skills below it.
+type SkillRequirement struct {
+	Skill     Skill
+	Related   []Skill
+	Employees []SkilledEmployee
+}
+
+// Terms names the skill, its aliases and everything below it, to widen a
+// search for the skill.
+func (r SkillRequirement) Terms() []string {
+	terms := append([]string{r.Skill.Name}, r.Skill.Aliases...)
+	for _, related := range r.Related {
+		terms = append(terms, related.Name)
+	}
+	return terms
+}
+
+// Employees listed per requirement, the most experienced first.
+const maxSkilledEmployees = 10
+
+// MatchSkillRequirements finds the skills mentioned in a question and the
+// employees having them. A requirement for "Kubernetes" also finds employees
+// who know "Amazon EKS" or wrote "k8s". Only employees passing the filter are
+// listed.
+func MatchSkillRequirements(db DB, text string, filter EmployeeFilter) ([]SkillRequirement, error) {
+	taxonomy, err := LoadSkillTaxonomy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var requirements []SkillRequirement
+	for _, skill := range taxonomy.FindInText(text) {
+		requirement := SkillRequirement{Skill: skill}
+		skillIds := []int{}
+		for _, descendant := range taxonomy.Descendants(skill.Id) {
+			skillIds = append(skillIds, descendant.Id)
+			if descendant.Id != skill.Id {
+				requirement.Related = append(requirement.Related, descendant)
+			}
+		}
+
+		rows, err := db.Query(
+			context.Background(),
+			`SELECT DISTINCT ON (users.id) users.id, COALESCE(users.name, ''), employee_skills.skill, employee_skills.years_experience::float8, employee_skills.last_used_year
+			FROM employee_skills
+			JOIN users ON users.id = employee_skills.user_id
+			WHERE employee_skills.skill_id = ANY($1) AND `+employeeFilterSQL(2)+`
+			ORDER BY users.id, employee_skills.years_experience DESC NULLS LAST`,
+			append([]any{skillIds}, filter.args()...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var employee SkilledEmployee
+			if err := rows.Scan(&employee.UserId, &employee.Name, &employee.Skill, &employee.YearsExperience, &employee.LastUsedYear); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			requirement.Employees = append(requirement.Employees, employee)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		sort.SliceStable(requirement.Employees, func(i, j int) bool {
+			a, b := requirement.Employees[i].YearsExperience, requirement.Employees[j].YearsExperience
+			return a != nil && (b == nil || *a > *b)
+		})
+		if len(requirement.Employees) > maxSkilledEmployees {
+			requirement.Employees = requirement.Employees[:maxSkilledEmployees]
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
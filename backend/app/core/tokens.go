@@ -0,0 +1,37 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CountTokens estimates how many tokens a model needs for text. Subword
+// tokenizers use about four characters per token for English words, non
+// Latin scripts like Cyrillic need roughly twice as many tokens, and every
+// punctuation character is usually a token of its own. The estimate errs on
+// the high side, so budgets based on it are not exceeded.
+func CountTokens(text string) int {
+	tokens := 0
+	for _, word := range strings.Fields(text) {
+		letters, latin, punctuation := 0, true, 0
+		for _, r := range word {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				letters++
+				if r >= utf8.RuneSelf && !unicode.Is(unicode.Latin, r) {
+					latin = false
+				}
+			default:
+				punctuation++
+			}
+		}
+
+		charsPerToken := 4
+		if !latin {
+			charsPerToken = 2
+		}
+		tokens += (letters+charsPerToken-1)/charsPerToken + punctuation
+	}
+	return tokens
+}
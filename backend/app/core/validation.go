@@ -2,8 +2,12 @@ package core
 
 import (
 	"errors"
+	"math"
 	"unicode"
 	"net/mail"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func ValidateEmail(email string) (string, error) {
@@ -71,3 +75,33 @@ func CheckPasswordMatch(password, repeatedPassword string) (string, error) {
 	}
 	return "", nil
 }
+
+// ParseSkill validates a skill entered on the profile page. Years of
+// experience and the last year used are optional.
+func ParseSkill(name, yearsExperience, lastUsedYear string) (EmployeeSkill, string, error) {
+	skill := EmployeeSkill{Skill: NormalizeSkillName(name), Source: SkillSourceManual}
+	if skill.Skill == "" {
+		return skill, "skillEmpty", errors.New("skill name is required")
+	}
+	if len([]rune(skill.Skill)) > 60 {
+		return skill, "skillTooLong", errors.New("skill name is too long")
+	}
+
+	if yearsExperience = strings.TrimSpace(yearsExperience); yearsExperience != "" {
+		years, err := strconv.ParseFloat(strings.Replace(yearsExperience, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(years) || years < 0 || years > 60 {
+			return skill, "badSkillYears", errors.New("invalid years of experience")
+		}
+		skill.YearsExperience = &years
+	}
+
+	if lastUsedYear = strings.TrimSpace(lastUsedYear); lastUsedYear != "" {
+		year, err := strconv.Atoi(lastUsedYear)
+		if err != nil || year < 1950 || year > time.Now().Year() {
+			return skill, "badSkillYear", errors.New("invalid last used year")
+		}
+		skill.LastUsedYear = &year
+	}
+
+	return skill, "", nil
+}
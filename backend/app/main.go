@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"mime"
+	"os"
 	"net/http"
+	"strconv"
 	"time"
 	
 	"github.com/a-h/templ"
@@ -13,23 +21,105 @@ import (
 	"teamforger/backend/pages/home"
 	"teamforger/backend/pages/uploadCV"
 	"teamforger/backend/pages/buildTeam"
+	"teamforger/backend/pages/cvSource"
+	"teamforger/backend/pages/cvVersions"
+	"teamforger/backend/pages/employees"
+	"teamforger/backend/pages/profile"
+	"teamforger/backend/pages/projectSearch"
+	"teamforger/backend/pages/search"
+	"teamforger/backend/pages/skillTaxonomy"
 )
 
 func main() {
+	chatProvider, err := core.NewChatProvider()
+	if err != nil {
+		log.Fatalf("Could not set up the chat provider: %v", err)
+	}
+
+	embedder, err := core.NewEmbedder()
+	if err != nil {
+		log.Fatalf("Could not set up the embedder: %v", err)
+	}
+
+	pool, err := core.NewPool()
+	if err != nil {
+		log.Fatalf("Database connection failed: %v", err)
+	}
+	defer pool.Close()
+
+	// ./teamforger migrate status|up|down manages the schema without starting the server.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(pool, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		applied, err := core.MigrateUp(pool, false)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		for _, migration := range applied {
+			fmt.Printf("Applied migration %04d_%s\n", migration.Version, migration.Name)
+		}
+	}
+
+	// ./teamforger import-skills FILE loads a skill taxonomy from a CSV or YAML file.
+	if len(os.Args) > 1 && os.Args[1] == "import-skills" {
+		if err := importSkills(pool, os.Args[2:]); err != nil {
+			log.Fatalf("Skill import failed: %v", err)
+		}
+		return
+	}
+
+	chunker, err := uploadCV.NewChunker()
+	if err != nil {
+		log.Fatalf("Could not load the CV section profiles: %v", err)
+	}
+
+	// ./teamforger extract-projects parses the projects of CVs uploaded before
+	// projects were extracted.
+	if len(os.Args) > 1 && os.Args[1] == "extract-projects" {
+		found, err := uploadCV.ExtractMissingProjects(pool, chunker)
+		if err != nil {
+			log.Fatalf("Project extraction failed: %v", err)
+		}
+		fmt.Printf("Extracted %d projects\n", found)
+		return
+	}
+
+	if err := core.CheckEmbeddingDimension(pool, embedder); err != nil {
+		log.Fatalf("Embedding model check failed: %v", err)
+	}
+
+	skillExtractor := uploadCV.NewSkillExtractor(chatProvider)
+	cvWorkers := uploadCV.NewCVWorkerPool(pool, embedder, chunker, skillExtractor, core.NewCVJobConfig())
+	cvWorkers.Start(context.Background())
+
+	retrievalConfig := core.NewRetrievalConfig()
+	reranker, err := core.NewReranker(chatProvider)
+	if err != nil {
+		log.Fatalf("Could not set up the reranker: %v", err)
+	}
+	retrievalConfig.Rerank = core.NewReranking(reranker)
+	queryRewriter := core.NewQueryRewriter(chatProvider)
+	contextBudget := core.NewContextBudget()
+
 	http.Handle("/", http.RedirectHandler("/signup", http.StatusSeeOther))
 	
-	http.HandleFunc("/home", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
+	http.HandleFunc("/home", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
 		templ.Handler(home.Home(user)).ServeHTTP(w, r)
 	}))
 	
-	http.HandleFunc("/signin", core.WithDBConnection(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn) {
-		if core.RedirectIfAuthorized(conn, w, r, "/home") {
+	http.HandleFunc("/signin", core.WithDBConnection(pool, func(w http.ResponseWriter, r *http.Request, db core.DB) {
+		if core.RedirectIfAuthorized(db, w, r, "/home") {
 			return
 		}
 		templ.Handler(signin.SignIn()).ServeHTTP(w, r)
 	}))
 	
-	http.HandleFunc("/process-signin", core.WithDBConnection(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn) {
+	http.HandleFunc("/process-signin", core.WithDBConnection(pool, func(w http.ResponseWriter, r *http.Request, db core.DB) {
 		user := core.User{
 			Email:    r.FormValue("email"),
 			Password: r.FormValue("password"),
@@ -41,7 +131,7 @@ func main() {
 			return
 		}
 
-		userDB, err := core.GetUserData(conn, user.Email)
+		userDB, err := core.GetUserData(db, user.Email)
 		if err != nil {
 			http.Redirect(w, r, "/signin?error=emailNotFound", http.StatusSeeOther)
 			return
@@ -57,7 +147,7 @@ func main() {
 			return
 		}
 
-		if err := core.UpdateUserTokens(conn, user); err != nil {
+		if err := core.UpdateUserTokens(db, user); err != nil {
 			http.Redirect(w, r, "/signin?error=tokenUpdateFailed", http.StatusSeeOther)
 			return
 		}
@@ -65,14 +155,14 @@ func main() {
 		http.Redirect(w, r, "/home?success=welcomeBack", http.StatusSeeOther)
 	}))
 	
-	http.HandleFunc("/signup", core.WithDBConnection(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn) {
-		if core.RedirectIfAuthorized(conn, w, r, "/home") {
+	http.HandleFunc("/signup", core.WithDBConnection(pool, func(w http.ResponseWriter, r *http.Request, db core.DB) {
+		if core.RedirectIfAuthorized(db, w, r, "/home") {
 			return
 		}
 		templ.Handler(signup.SignUp()).ServeHTTP(w, r)
 	}))
 	
-	http.HandleFunc("/process-signup", core.WithDBConnection(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn) {
+	http.HandleFunc("/process-signup", core.WithDBConnection(pool, func(w http.ResponseWriter, r *http.Request, db core.DB) {
 		user := core.User{
 			Name:             r.FormValue("name"),
 			Email:            r.FormValue("email"),
@@ -99,7 +189,7 @@ func main() {
 			return
 		}
 
-		if err := signup.CreateUser(conn, user); err != nil {
+		if err := signup.CreateUser(db, user); err != nil {
 			if err.Error() == "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)" {
 				http.Redirect(w, r, "/signup?error=duplicateEmail", http.StatusSeeOther)
 			} else {
@@ -111,7 +201,7 @@ func main() {
 		http.Redirect(w, r, "/home?success=accountCreated", http.StatusSeeOther)
 	}))
 	
-	http.HandleFunc("/signout", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
+	http.HandleFunc("/signout", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
 		// Clear cookies
 		http.SetCookie(w, &http.Cookie{
 			Name:    "session_token",
@@ -135,7 +225,7 @@ func main() {
 			SessionToken: "",
 			CSRFToken:    "",
 		}
-		if err := core.UpdateUserTokens(conn, emptyUser); err != nil {
+		if err := core.UpdateUserTokens(db, emptyUser); err != nil {
 			http.Redirect(w, r, "/signin?error=tokenClearFailed", http.StatusSeeOther)
 			return
 		}
@@ -143,46 +233,715 @@ func main() {
 		http.Redirect(w, r, "/signin?success=signedOut", http.StatusSeeOther)
 	}))
 	
-	http.HandleFunc("/uploadCV", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
-		templ.Handler(uploadCV.UploadCV(user)).ServeHTTP(w, r)
+	http.HandleFunc("/uploadCV", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		// Show the job just submitted, or the latest one while it is still running.
+		var job core.CVJob
+		if jobId, err := strconv.Atoi(r.URL.Query().Get("job")); err == nil {
+			job, err = core.GetCVJob(db, jobId, user.Id)
+			if err != nil {
+				http.Redirect(w, r, "/uploadCV?error=cvJobNotFound", http.StatusSeeOther)
+				return
+			}
+		} else if latest, err := core.GetLatestCVJob(db, user.Id); err == nil && !latest.Finished() {
+			job = latest
+		}
+
+		templ.Handler(uploadCV.UploadCV(user, job)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/uploadCVStatus", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		jobId, err := strconv.Atoi(r.URL.Query().Get("job"))
+		if err != nil {
+			http.Error(w, "Invalid job id", http.StatusBadRequest)
+			return
+		}
+
+		job, err := core.GetCVJob(db, jobId, user.Id)
+		if err != nil {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(job)
 	}))
 	
-	http.HandleFunc("/process-uploadCV", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
-		fileContents, err := core.ReceiveFile(w, r)
+	http.HandleFunc("/process-uploadCV", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		fileContents, fileName, err := core.ReceiveFile(w, r)
 		if err != nil {
 			http.Redirect(w, r, "/home?error=fileUploadError", http.StatusSeeOther)
 			return
 		}
 
-		markdownContent, err := core.DocxToMarkDown(fileContents)
+		// Reject unsupported files right away, the rest happens in the background.
+		if _, ok := core.DetectCVFormat(fileContents, fileName); !ok {
+			http.Redirect(w, r, "/uploadCV?error=unsupportedFileType", http.StatusSeeOther)
+			return
+		}
+
+		jobId, err := uploadCV.EnqueueCVJob(db, user.Id, fileName, fileContents)
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/uploadCV?error=cvStorageFailed", http.StatusSeeOther)
+			return
+		}
+		cvWorkers.Notify()
+
+		http.Redirect(w, r, "/uploadCV?job="+strconv.Itoa(jobId), http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/cvVersions", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		// Everyone sees their own history, administrators also the one of any employee.
+		employeeId := user.Id
+		if requested, err := strconv.Atoi(r.URL.Query().Get("user")); err == nil && requested != user.Id {
+			if user.IsAdmin != true {
+				http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+				return
+			}
+			employeeId = requested
+		}
+
+		employee, err := cvVersions.GetEmployee(db, employeeId)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		versions, err := cvVersions.ListCVVersions(db, employee.Id)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=cvVersionsError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(cvVersions.CVVersions(user, employee, versions)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/cvVersionDiff", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		fromId, fromErr := strconv.Atoi(r.URL.Query().Get("from"))
+		toId, toErr := strconv.Atoi(r.URL.Query().Get("to"))
+		if fromErr != nil || toErr != nil {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		from, fromErr := cvVersions.GetCVVersion(db, fromId)
+		to, toErr := cvVersions.GetCVVersion(db, toId)
+		if fromErr != nil || toErr != nil || from.UserId != to.UserId || !canAccessCV(user, from.UserId) {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		employee, err := cvVersions.GetEmployee(db, from.UserId)
+		if err != nil {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		diff := core.DiffLines(from.Markdown, to.Markdown)
+		templ.Handler(cvVersions.CVVersionDiff(user, employee, from, to, diff)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/downloadCVVersion", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		versionId, err := strconv.Atoi(r.URL.Query().Get("version"))
+		if err != nil {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		version, err := cvVersions.GetCVVersion(db, versionId)
+		if err != nil || !canAccessCV(user, version.UserId) {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		w.Header().Set("Content-Type", http.DetectContentType(version.Original))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": version.FileName}))
+		w.Write(version.Original)
+	}))
+
+	// Opens the CV section a citation in a chat answer points to.
+	http.HandleFunc("/cvSource", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		chunkId, err := strconv.Atoi(r.URL.Query().Get("chunk"))
+		if err != nil {
+			http.Redirect(w, r, "/home?error=cvSourceNotFound", http.StatusSeeOther)
+			return
+		}
+
+		source, err := cvSource.GetCVSource(db, chunkId)
+		if err != nil || !canAccessCV(user, source.Version.UserId) {
+			http.Redirect(w, r, "/home?error=cvSourceNotFound", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(cvSource.CVSource(user, source)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/process-restoreCVVersion", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		versionId, err := strconv.Atoi(r.FormValue("version"))
+		if err != nil {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		version, err := cvVersions.GetCVVersion(db, versionId)
+		if err != nil || !canAccessCV(user, version.UserId) {
+			http.Redirect(w, r, "/cvVersions?error=cvVersionNotFound", http.StatusSeeOther)
+			return
+		}
+
+		redirectURL := "/cvVersions?user=" + strconv.Itoa(version.UserId)
+		if err := cvVersions.RestoreCVVersion(db, version.Id, skillExtractor, chunker); err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, redirectURL+"&error=cvVersionRestoreFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, redirectURL+"&success=cvVersionRestored", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/profile", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		skills, err := profile.ListSkills(db, user.Id)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=skillsError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(profile.Profile(user, skills)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/process-addSkill", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		skill, urlParam, err := core.ParseSkill(r.FormValue("skill"), r.FormValue("years"), r.FormValue("last_used"))
+		if err != nil {
+			http.Redirect(w, r, "/profile?error="+urlParam, http.StatusSeeOther)
+			return
+		}
+
+		if err := profile.AddSkill(db, user.Id, skill); err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/profile?error=skillSaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/profile?success=skillSaved", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-updateSkill", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		skillId, err := strconv.Atoi(r.FormValue("skill_id"))
+		if err != nil {
+			http.Redirect(w, r, "/profile?error=skillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		skill, urlParam, err := core.ParseSkill(r.FormValue("skill"), r.FormValue("years"), r.FormValue("last_used"))
+		if err != nil {
+			http.Redirect(w, r, "/profile?error="+urlParam, http.StatusSeeOther)
+			return
+		}
+
+		if err := profile.UpdateSkill(db, skillId, user.Id, skill); err != nil {
+			// Renaming onto another skill of the employee violates the unique index.
+			fmt.Println(err)
+			http.Redirect(w, r, "/profile?error=skillSaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/profile?success=skillSaved", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-deleteSkill", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		skillId, err := strconv.Atoi(r.FormValue("skill_id"))
+		if err != nil {
+			http.Redirect(w, r, "/profile?error=skillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		if err := profile.DeleteSkill(db, skillId, user.Id); err != nil {
+			http.Redirect(w, r, "/profile?error=skillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/profile?success=skillDeleted", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/skillTaxonomy", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		usage, err := skillTaxonomy.ListSkillUsage(db)
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/home?error=taxonomyError", http.StatusSeeOther)
+			return
+		}
+
+		unlinked, err := skillTaxonomy.ListUnlinkedSkills(db)
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/home?error=taxonomyError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(skillTaxonomy.SkillTaxonomy(user, usage, unlinked)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/process-importSkillTaxonomy", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		fileContents, fileName, err := core.ReceiveFile(w, r)
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=fileUploadError", http.StatusSeeOther)
+			return
+		}
+
+		entries, err := skillTaxonomy.ParseTaxonomyFile(fileContents, fileName)
+		if errors.Is(err, skillTaxonomy.ErrUnsupportedTaxonomyFormat) {
+			http.Redirect(w, r, "/skillTaxonomy?error=unsupportedTaxonomyFile", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomyImportInvalid", http.StatusSeeOther)
+			return
+		}
+
+		if _, err := skillTaxonomy.ImportTaxonomy(db, entries); err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomyImportInvalid", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/skillTaxonomy?success=taxonomyImported", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-createSkill", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		skill, urlParam, err := core.ParseSkill(r.FormValue("name"), "", "")
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error="+urlParam, http.StatusSeeOther)
+			return
+		}
+
+		var parentId *int
+		if parent, err := strconv.Atoi(r.FormValue("parent")); err == nil {
+			parentId = &parent
+		}
+
+		err = skillTaxonomy.CreateSkill(db, skill.Skill, parentId)
+		if errors.Is(err, skillTaxonomy.ErrSkillExists) {
+			http.Redirect(w, r, "/skillTaxonomy?error=skillExists", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/skillTaxonomy?success=skillCreated", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-addSkillAlias", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		skillId, err := strconv.Atoi(r.FormValue("skill_id"))
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		alias, urlParam, err := core.ParseSkill(r.FormValue("alias"), "", "")
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error="+urlParam, http.StatusSeeOther)
+			return
+		}
+
+		err = skillTaxonomy.AddAlias(db, skillId, alias.Skill)
+		if errors.Is(err, skillTaxonomy.ErrAliasIsSkill) {
+			http.Redirect(w, r, "/skillTaxonomy?error=aliasIsSkill", http.StatusSeeOther)
+			return
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/skillTaxonomy?success=skillAliasSaved", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-deleteSkillAlias", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		skillId, err := strconv.Atoi(r.FormValue("skill_id"))
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		if err := skillTaxonomy.DeleteAlias(db, skillId, r.FormValue("alias")); err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/skillTaxonomy?success=skillAliasDeleted", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-mergeSkills", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		fromId, err := strconv.Atoi(r.FormValue("from"))
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+		intoId, err := strconv.Atoi(r.FormValue("into"))
+		if err != nil {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+
+		err = skillTaxonomy.MergeSkills(db, fromId, intoId)
+		if errors.Is(err, skillTaxonomy.ErrSameSkill) {
+			http.Redirect(w, r, "/skillTaxonomy?error=sameSkill", http.StatusSeeOther)
+			return
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySkillNotFound", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/skillTaxonomy?error=taxonomySaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/skillTaxonomy?success=skillsMerged", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/projects", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		filter := core.ProjectFilter{
+			Client:     r.URL.Query().Get("client"),
+			Technology: r.URL.Query().Get("technology"),
+			Role:       r.URL.Query().Get("role"),
+		}
+		if within, err := strconv.Atoi(r.URL.Query().Get("within")); err == nil && within > 0 {
+			filter.WithinYears = min(within, 50)
+		}
+
+		projects, err := projectSearch.SearchProjects(db, filter)
+		if err != nil {
+			fmt.Println(err)
+			http.Redirect(w, r, "/home?error=projectsError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(projectSearch.ProjectSearch(user, filter, projects)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/employees", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		employeeList, err := employees.ListEmployees(db)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=employeesError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(employees.Employees(user, employeeList)).ServeHTTP(w, r)
+	}))
+
+	http.HandleFunc("/process-updateEmployeeAttributes", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		employeeId, err := strconv.Atoi(r.FormValue("user_id"))
+		if err != nil {
+			http.Redirect(w, r, "/employees?error=employeeNotFound", http.StatusSeeOther)
+			return
+		}
+
+		attributes := core.EmployeeAttributes{
+			Department:       r.FormValue("department"),
+			Location:         r.FormValue("location"),
+			Seniority:        r.FormValue("seniority"),
+			EmploymentStatus: r.FormValue("employment_status"),
+		}
+		if err := employees.UpdateAttributes(db, employeeId, attributes); err != nil {
+			switch {
+			case errors.Is(err, employees.ErrInvalidAttributes):
+				http.Redirect(w, r, "/employees?error=employeeAttributesInvalid", http.StatusSeeOther)
+			case errors.Is(err, pgx.ErrNoRows):
+				http.Redirect(w, r, "/employees?error=employeeNotFound", http.StatusSeeOther)
+			default:
+				http.Redirect(w, r, "/employees?error=employeeSaveFailed", http.StatusSeeOther)
+			}
+			return
+		}
+
+		http.Redirect(w, r, "/employees?success=employeeSaved", http.StatusSeeOther)
+	}))
+
+	// Searches the CVs like the assistant does, restricted by the employee
+	// filter in the query parameters, and returns the chunks as JSON.
+	http.HandleFunc("/search", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Error(w, "Administrators only", http.StatusForbidden)
+			return
+		}
+
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			http.Error(w, "Missing query", http.StatusBadRequest)
+			return
+		}
+
+		config := retrievalConfig
+		filter, err := search.ParseFilter(r.URL.Query(), config.Filter)
 		if err != nil {
-			http.Redirect(w, r, "/home?error=docxConversionError", http.StatusSeeOther)
+			http.Error(w, "Invalid filter", http.StatusBadRequest)
 			return
 		}
+		config.Filter = filter
+		if k, err := strconv.Atoi(r.URL.Query().Get("k")); err == nil && k > 0 {
+			config.TopK = min(k, 50)
+		}
 
-		user.CV = markdownContent
-		if err := uploadCV.StoreUserCV(conn, user); err != nil {
+		results, err := search.Search(db, embedder, query, config)
+		if err != nil {
 			fmt.Println(err)
-			http.Redirect(w, r, "/home?error=cvStorageFailed", http.StatusSeeOther)
+			http.Error(w, "Search failed", http.StatusInternalServerError)
 			return
 		}
 
-		http.Redirect(w, r, "/home?success=CVConverted", http.StatusSeeOther)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(results)
 	}))
 
-	http.HandleFunc("/buildTeam", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
+	http.HandleFunc("/buildTeam", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
 		if user.IsAdmin != true {
 			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
 			return
 		}
-		templ.Handler(buildTeam.BuildTeam(user)).ServeHTTP(w, r)
+
+		conversationList, err := buildTeam.ListConversations(db, user.Id)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=conversationError", http.StatusSeeOther)
+			return
+		}
+
+		// Without an explicit conversation, continue the latest one or start the first one.
+		conversationId, err := strconv.Atoi(r.URL.Query().Get("conversation"))
+		if err != nil {
+			if len(conversationList) > 0 {
+				conversationId = conversationList[0].Id
+			} else if conversationId, err = buildTeam.CreateConversation(db, user.Id); err != nil {
+				http.Redirect(w, r, "/home?error=conversationError", http.StatusSeeOther)
+				return
+			}
+			// Keep any notification params for the redirected page.
+			query := r.URL.Query()
+			query.Set("conversation", strconv.Itoa(conversationId))
+			http.Redirect(w, r, "/buildTeam?"+query.Encode(), http.StatusSeeOther)
+			return
+		}
+
+		conversation, err := core.GetConversation(db, conversationId, user.Id)
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationNotFound", http.StatusSeeOther)
+			return
+		}
+
+		messages, err := core.GetMessages(db, conversation.Id)
+		if err != nil {
+			http.Redirect(w, r, "/home?error=conversationError", http.StatusSeeOther)
+			return
+		}
+
+		templ.Handler(buildTeam.BuildTeam(user, conversationList, conversation, messages, retrievalConfig)).ServeHTTP(w, r)
 	}))
 
-	http.HandleFunc("/ws", core.WithAuthorization(func(w http.ResponseWriter, r *http.Request, conn *pgx.Conn, user core.User) {
-		core.HandleChat(w, r, conn, user)
+	http.HandleFunc("/process-newConversation", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		if user.IsAdmin != true {
+			http.Redirect(w, r, "/home?error=notAdmin", http.StatusSeeOther)
+			return
+		}
+
+		conversationId, err := buildTeam.CreateConversation(db, user.Id)
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationError", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId), http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-renameConversation", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		conversationId, err := strconv.Atoi(r.FormValue("conversation"))
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationNotFound", http.StatusSeeOther)
+			return
+		}
+
+		if err := buildTeam.RenameConversation(db, conversationId, user.Id, r.FormValue("title")); err != nil {
+			http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId)+"&error=conversationRenameFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId)+"&success=conversationRenamed", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-conversationRetrieval", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		conversationId, err := strconv.Atoi(r.FormValue("conversation"))
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationNotFound", http.StatusSeeOther)
+			return
+		}
+
+		override, err := buildTeam.ParseRetrievalOverride(r.FormValue("top_k"), r.FormValue("min_similarity"), r.FormValue("mmr_lambda"))
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId)+"&error=retrievalInvalid", http.StatusSeeOther)
+			return
+		}
+
+		if err := buildTeam.SetConversationRetrieval(db, conversationId, user.Id, override); err != nil {
+			http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId)+"&error=retrievalSaveFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/buildTeam?conversation="+strconv.Itoa(conversationId)+"&success=retrievalSaved", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/process-deleteConversation", core.WithAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		conversationId, err := strconv.Atoi(r.FormValue("conversation"))
+		if err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationNotFound", http.StatusSeeOther)
+			return
+		}
+
+		if err := buildTeam.DeleteConversation(db, conversationId, user.Id); err != nil {
+			http.Redirect(w, r, "/buildTeam?error=conversationDeleteFailed", http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/buildTeam?success=conversationDeleted", http.StatusSeeOther)
+	}))
+
+	http.HandleFunc("/ws", core.WithPoolAuthorization(pool, func(w http.ResponseWriter, r *http.Request, db core.DB, user core.User) {
+		core.HandleChat(w, r, db, user, chatProvider, embedder, retrievalConfig, queryRewriter, contextBudget)
 	}))
 
 
 	fmt.Println("Listening on :8080")
 	http.ListenAndServe(":8080", nil)
 }
+
+// canAccessCV reports whether the user may see and restore the CV versions of
+// an employee: their own, or anyone's for administrators.
+func canAccessCV(user core.User, employeeId int) bool {
+	return user.Id == employeeId || user.IsAdmin
+}
+
+func importSkills(pool *core.Pool, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: teamforger import-skills FILE.csv|FILE.yaml")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	entries, err := skillTaxonomy.ParseTaxonomyFile(data, args[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := skillTaxonomy.ImportTaxonomy(pool, entries)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d entries: %d new skills, %d aliases added or moved\n", len(entries), result.Skills, result.Aliases)
+	return nil
+}
+
+func migrate(pool *core.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: teamforger migrate status | up [-dry-run] | down [-steps N] [-dry-run]")
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the migrations that would run")
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "status":
+		states, err := core.MigrationStatus(pool)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if state.Applied {
+				fmt.Printf("%04d_%s\tapplied %s\n", state.Version, state.Name, state.AppliedAt.Format(time.RFC3339))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", state.Version, state.Name)
+			}
+		}
+		return nil
+	case "up":
+		applied, err := core.MigrateUp(pool, *dryRun)
+		for _, migration := range applied {
+			if *dryRun {
+				fmt.Printf("Would apply %04d_%s\n", migration.Version, migration.Name)
+			} else {
+				fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+			}
+		}
+		return err
+	case "down":
+		reverted, err := core.MigrateDown(pool, *steps, *dryRun)
+		for _, migration := range reverted {
+			if *dryRun {
+				fmt.Printf("Would revert %04d_%s\n", migration.Version, migration.Name)
+			} else {
+				fmt.Printf("Reverted %04d_%s\n", migration.Version, migration.Name)
+			}
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
@@ -1,8 +1,126 @@
 package buildTeam
 
 import (
-	// "teamforger/backend/core"
-	// "github.com/jackc/pgx/v5"
-	// "context"
+	"teamforger/backend/core"
+	"github.com/jackc/pgx/v5"
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
 )
 
+var ErrInvalidRetrieval = errors.New("invalid retrieval settings")
+
+// The most chunks or employees a conversation can ask for, more only bury
+// the question in context.
+const maxRetrievalTopK = 20
+
+func ListConversations(db core.DB, userId int) ([]core.Conversation, error) {
+	rows, err := db.Query(
+		context.Background(),
+		"SELECT id, user_id, title, created_at, updated_at FROM conversations WHERE user_id = $1 ORDER BY updated_at DESC",
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []core.Conversation
+	for rows.Next() {
+		var conversation core.Conversation
+		if err := rows.Scan(&conversation.Id, &conversation.UserId, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt); err != nil {
+			return conversations, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, rows.Err()
+}
+
+func CreateConversation(db core.DB, userId int) (int, error) {
+	var conversationId int
+	err := db.QueryRow(
+		context.Background(),
+		"INSERT INTO conversations (user_id, title) VALUES ($1, $2) RETURNING id",
+		userId, core.DefaultConversationTitle).Scan(&conversationId)
+	if err != nil {
+		return -1, err
+	}
+	return conversationId, nil
+}
+
+func RenameConversation(db core.DB, conversationId int, userId int, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("conversation title is required")
+	}
+
+	tag, err := db.Exec(
+		context.Background(),
+		"UPDATE conversations SET title = $1 WHERE id = $2 AND user_id = $3",
+		title, conversationId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteConversation(db core.DB, conversationId int, userId int) error {
+	tag, err := db.Exec(
+		context.Background(),
+		"DELETE FROM conversations WHERE id = $1 AND user_id = $2",
+		conversationId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ParseRetrievalOverride reads the retrieval settings form of a conversation,
+// an empty field keeps the deployment default.
+func ParseRetrievalOverride(topK string, minSimilarity string, mmrLambda string) (core.RetrievalOverride, error) {
+	var override core.RetrievalOverride
+	if topK = strings.TrimSpace(topK); topK != "" {
+		value, err := strconv.Atoi(topK)
+		if err != nil || value < 1 || value > maxRetrievalTopK {
+			return override, ErrInvalidRetrieval
+		}
+		override.TopK = &value
+	}
+	if minSimilarity = strings.TrimSpace(minSimilarity); minSimilarity != "" {
+		value, err := strconv.ParseFloat(minSimilarity, 64)
+		if err != nil || math.IsNaN(value) || value < -1 || value > 1 {
+			return override, ErrInvalidRetrieval
+		}
+		override.MinSimilarity = &value
+	}
+	if mmrLambda = strings.TrimSpace(mmrLambda); mmrLambda != "" {
+		value, err := strconv.ParseFloat(mmrLambda, 64)
+		if err != nil || math.IsNaN(value) || value < 0 || value > 1 {
+			return override, ErrInvalidRetrieval
+		}
+		override.MMRLambda = &value
+	}
+	return override, nil
+}
+
+func SetConversationRetrieval(db core.DB, conversationId int, userId int, override core.RetrievalOverride) error {
+	tag, err := db.Exec(
+		context.Background(),
+		`UPDATE conversations SET retrieval_top_k = $1, retrieval_min_similarity = $2, retrieval_mmr_lambda = $3
+		WHERE id = $4 AND user_id = $5`,
+		override.TopK, override.MinSimilarity, override.MMRLambda, conversationId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
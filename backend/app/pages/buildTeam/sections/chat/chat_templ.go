@@ -9,10 +9,23 @@ import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
 import (
+	"encoding/json"
+	"strconv"
 	"teamforger/backend/core"
 )
 
-func Chat(user core.User) templ.Component {
+func sourcesJSON(sources []core.ContextSource) string {
+	if len(sources) == 0 {
+		return "[]"
+	}
+	encoded, err := json.Marshal(sources)
+	if err != nil {
+		return "[]"
+	}
+	return string(encoded)
+}
+
+func Chat(user core.User, conversation core.Conversation, messages []core.Message) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -33,7 +46,99 @@ func Chat(user core.User) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"col-md-12 col-lg-12\"><div class=\"card p-4\"><div class=\"d-flex flex-column h-100\"><!-- Full-height chat container --><div id=\"chat-messages\" class=\"flex-grow-1 overflow-auto p-4 bg-light rounded mb-3\" style=\"height: 70vh;\"><!-- Placeholder that will disappear after first message --><div id=\"chat-placeholder\" class=\"text-center text-muted py-4\">Start chatting with your team building assistant...</div></div><div class=\"d-flex mt-auto\"><!-- Full-width input field --><input type=\"text\" id=\"chat-input\" class=\"form-control me-2 p-3\" placeholder=\"Type your message...\" style=\"font-size: 1.2rem;\"><!-- Larger button --><button class=\"btn btn-primary px-4 py-3\" id=\"send-button\" style=\"font-size: 1.2rem; min-width: 120px;\">Send</button></div></div></div></div><script>\n\t\tdocument.addEventListener('DOMContentLoaded', function() {\n\t\t\tconst chatInput = document.getElementById('chat-input');\n\t\t\tconst sendButton = document.getElementById('send-button');\n\t\t\tconst chatMessages = document.getElementById('chat-messages');\n\t\t\tconst chatPlaceholder = document.getElementById('chat-placeholder');\n\t\t\t\n\t\t\tlet socket = null;\n\t\t\tlet currentAssistantMessage = null;\n\t\t\tlet assistantMessageContent = '';\n\t\t\tlet reconnectAttempts = 0;\n\t\t\tconst maxReconnectAttempts = 5;\n\t\t\tconst reconnectDelayBase = 1000; // 1 second\n\t\t\t\n\t\t\tfunction connectWebSocket() {\n\t\t\t\tconst protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';\n\t\t\t\tsocket = new WebSocket(protocol + '//' + window.location.host + '/ws');\n\t\t\t\t\n\t\t\t\tsocket.onopen = function() {\n\t\t\t\t\treconnectAttempts = 0;\n\t\t\t\t\tconsole.log('WebSocket connection established');\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onmessage = function(event) {\n\t\t\t\t\tconst message = event.data;\n\t\t\t\t\t\n\t\t\t\t\t// Handle ping requests from server\n\t\t\t\t\tif (message === \"ping\") {\n\t\t\t\t\t\tsocket.send(\"pong\");\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Remove placeholder on first message\n\t\t\t\t\tif (chatPlaceholder) {\n\t\t\t\t\t\tchatPlaceholder.remove();\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Create new assistant message if none exists\n\t\t\t\t\tif (!currentAssistantMessage) {\n\t\t\t\t\t\tcurrentAssistantMessage = document.createElement('div');\n\t\t\t\t\t\tcurrentAssistantMessage.className = 'assistant-message mb-3';\n\t\t\t\t\t\tchatMessages.appendChild(currentAssistantMessage);\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Append content\n\t\t\t\t\tassistantMessageContent += message;\n\t\t\t\t\tcurrentAssistantMessage.innerHTML = marked.parse(assistantMessageContent);\n\t\t\t\t\t\n\t\t\t\t\t// Scroll to bottom\n\t\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onclose = function(event) {\n\t\t\t\t\tconsole.log('WebSocket closed:', event);\n\t\t\t\t\tattemptReconnect();\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onerror = function(error) {\n\t\t\t\t\tconsole.error('WebSocket error:', error);\n\t\t\t\t};\n\t\t\t}\n\t\t\t\n\t\t\tfunction attemptReconnect() {\n\t\t\t\tif (reconnectAttempts >= maxReconnectAttempts) {\n\t\t\t\t\tconsole.error('Max reconnect attempts reached');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tconst delay = reconnectDelayBase * Math.pow(2, reconnectAttempts);\n\t\t\t\treconnectAttempts++;\n\t\t\t\t\n\t\t\t\tconsole.log(`Attempting reconnect in ${delay}ms (attempt ${reconnectAttempts}/${maxReconnectAttempts})`);\n\t\t\t\t\n\t\t\t\tsetTimeout(() => {\n\t\t\t\t\tconsole.log('Reconnecting...');\n\t\t\t\t\tconnectWebSocket();\n\t\t\t\t}, delay);\n\t\t\t}\n\t\t\t\n\t\t\tfunction sendMessage() {\n\t\t\t\tconst message = chatInput.value.trim();\n\t\t\t\tif (message && socket && socket.readyState === WebSocket.OPEN) {\n\t\t\t\t\t// Remove placeholder\n\t\t\t\t\tif (chatPlaceholder) {\n\t\t\t\t\t\tchatPlaceholder.remove();\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Reset assistant message tracking\n\t\t\t\t\tcurrentAssistantMessage = null;\n\t\t\t\t\tassistantMessageContent = '';\n\t\t\t\t\t\n\t\t\t\t\t// Add user message to UI\n\t\t\t\t\tconst userMessageDiv = document.createElement('div');\n\t\t\t\t\tuserMessageDiv.className = 'user-message mb-3 text-end';\n\t\t\t\t\tuserMessageDiv.innerHTML = marked.parse(`**You:** ${message}`);\n\t\t\t\t\tchatMessages.appendChild(userMessageDiv);\n\t\t\t\t\t\n\t\t\t\t\t// Send message via WebSocket\n\t\t\t\t\tsocket.send(message);\n\t\t\t\t\t\n\t\t\t\t\t// Clear input\n\t\t\t\t\tchatInput.value = '';\n\t\t\t\t\t\n\t\t\t\t\t// Scroll to bottom\n\t\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t\t\t\n\t\t\t\t\t// Focus input for next message\n\t\t\t\t\tchatInput.focus();\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\t// Send on button click\n\t\t\tsendButton.addEventListener('click', sendMessage);\n\t\t\t\n\t\t\t// Send on Enter key\n\t\t\tchatInput.addEventListener('keypress', function(e) {\n\t\t\t\tif (e.key === 'Enter') {\n\t\t\t\t\tsendMessage();\n\t\t\t\t}\n\t\t\t});\n\t\t\t\n\t\t\t// Initialize WebSocket connection\n\t\t\tconnectWebSocket();\n\t\t\t\n\t\t\t// Focus input on load\n\t\t\tchatInput.focus();\n\t\t});\n\t</script><style>\n\t\t/* Improved chat styling */\n\t\t#chat-messages {\n\t\t\tdisplay: flex;\n\t\t\tflex-direction: column;\n\t\t\tgap: 1.5rem;\n\t\t\tfont-size: 1.2rem;\n\t\t\tline-height: 1.8;\n\t\t}\n\t\t\n\t\t.user-message div, .assistant-message div {\n\t\t\tpadding: 1.2rem;\n\t\t\tborder-radius: 12px;\n\t\t\tdisplay: inline-block;\n\t\t\tmax-width: 90%;\n\t\t}\n\t\t\n\t\t.user-message div {\n\t\t\tbackground: linear-gradient(to right, #6a11cb, #2575fc);\n\t\t\tcolor: white;\n\t\t\tborder-bottom-right-radius: 4px;\n\t\t}\n\t\t\n\t\t.assistant-message div {\n\t\t\tbackground-color: #f8f9fa;\n\t\t\tborder: 1px solid #dee2e6;\n\t\t\tborder-bottom-left-radius: 4px;\n\t\t}\n\t\t\n\t\t/* Markdown styling */\n\t\t#chat-messages p {\n\t\t\tmargin-bottom: 0.8rem;\n\t\t}\n\t\t\n\t\t#chat-messages h1, \n\t\t#chat-messages h2, \n\t\t#chat-messages h3 {\n\t\t\tmargin-top: 1.5rem;\n\t\t\tmargin-bottom: 1rem;\n\t\t}\n\t\t\n\t\t#chat-messages ul, \n\t\t#chat-messages ol {\n\t\t\tpadding-left: 2rem;\n\t\t\tmargin-bottom: 1rem;\n\t\t}\n\t\t\n\t\t#chat-messages li {\n\t\t\tmargin-bottom: 0.5rem;\n\t\t}\n\t\t\n\t\t#chat-messages code {\n\t\t\tbackground-color: #e9ecef;\n\t\t\tpadding: 0.3rem 0.5rem;\n\t\t\tborder-radius: 6px;\n\t\t\tfont-family: monospace;\n\t\t\tfont-size: 1.1rem;\n\t\t}\n\t\t\n\t\t#chat-messages pre {\n\t\t\tbackground-color: #2d2d2d;\n\t\t\tcolor: #f8f8f2;\n\t\t\tpadding: 1rem;\n\t\t\tborder-radius: 6px;\n\t\t\toverflow-x: auto;\n\t\t\tmargin-bottom: 1.5rem;\n\t\t}\n\t\t\n\t\t#chat-messages pre code {\n\t\t\tbackground-color: transparent;\n\t\t\tpadding: 0;\n\t\t}\n\t</style>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"col-md-12 col-lg-9\"><div class=\"card p-4\"><div class=\"d-flex flex-column h-100\"><!-- Full-height chat container --><div id=\"chat-messages\" class=\"flex-grow-1 overflow-auto p-4 bg-light rounded mb-3\" style=\"height: 70vh;\" data-conversation-id=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(strconv.Itoa(conversation.Id))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/buildTeam/sections/chat/chat.templ`, Line: 25, Col: 161}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if len(messages) == 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<!-- Placeholder that will disappear after first message --> <div id=\"chat-placeholder\" class=\"text-center text-muted py-4\">Start chatting with your team building assistant...</div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<!-- Previous turns, rendered as markdown on load -->")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		for _, message := range messages {
+			if message.Role == "user" {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<div class=\"user-message mb-3 text-end\" data-markdown=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var3 string
+				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs("**You:** " + message.Content)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/buildTeam/sections/chat/chat.templ`, Line: 35, Col: 91}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "\"></div>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<div class=\"assistant-message mb-3\" data-markdown=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(message.Content)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/buildTeam/sections/chat/chat.templ`, Line: 37, Col: 73}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "\" data-sources=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var5 string
+				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(sourcesJSON(message.Sources))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/buildTeam/sections/chat/chat.templ`, Line: 37, Col: 119}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "\" data-truncated=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var6 string
+				templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(strconv.FormatBool(message.Truncated))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/buildTeam/sections/chat/chat.templ`, Line: 37, Col: 176}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\"></div>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div><div class=\"d-flex mt-auto\"><!-- Full-width input field --><input type=\"text\" id=\"chat-input\" class=\"form-control me-2 p-3\" placeholder=\"Type your message...\" style=\"font-size: 1.2rem;\"><!-- Larger button --><button class=\"btn btn-primary px-4 py-3\" id=\"send-button\" style=\"font-size: 1.2rem; min-width: 120px;\">Send</button><!-- Shown while an answer is being generated --><button class=\"btn btn-outline-danger px-4 py-3 ms-2 d-none\" id=\"stop-button\" style=\"font-size: 1.2rem; min-width: 120px;\"><i class=\"bi bi-stop-circle me-1\"></i>Stop</button></div></div></div></div><script>\n\t\tdocument.addEventListener('DOMContentLoaded', function() {\n\t\t\tconst chatInput = document.getElementById('chat-input');\n\t\t\tconst sendButton = document.getElementById('send-button');\n\t\t\tconst stopButton = document.getElementById('stop-button');\n\t\t\tconst chatMessages = document.getElementById('chat-messages');\n\t\t\tconst chatPlaceholder = document.getElementById('chat-placeholder');\n\t\t\tconst conversationId = chatMessages.dataset.conversationId;\n\t\t\t\n\t\t\t// Render the stored conversation\n\t\t\tchatMessages.querySelectorAll('[data-markdown]').forEach(function(messageDiv) {\n\t\t\t\tconst sources = JSON.parse(messageDiv.dataset.sources || '[]');\n\t\t\t\tmessageDiv.innerHTML = marked.parse(messageDiv.dataset.markdown);\n\t\t\t\tlinkCitations(messageDiv, sources);\n\t\t\t\tif (messageDiv.dataset.truncated === 'true') {\n\t\t\t\t\tmarkStopped(messageDiv);\n\t\t\t\t}\n\t\t\t\tappendSources(messageDiv, sources);\n\t\t\t});\n\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t\n\t\t\t// Every frame is a JSON envelope, see core/chat.go\n\t\t\tconst protocolVersion = 1;\n\t\t\tlet socket = null;\n\t\t\tlet currentAssistantMessage = null;\n\t\t\tlet assistantMessageContent = '';\n\t\t\tlet currentSources = [];\n\t\t\tlet waitingForResponse = false;\n\t\t\tlet currentTurn = 0; // replies to older turns are ignored\n\t\t\tlet heartbeatTimer = null;\n\t\t\tlet reconnectAttempts = 0;\n\t\t\tconst maxReconnectAttempts = 5;\n\t\t\tconst reconnectDelayBase = 1000; // 1 second\n\t\t\tconst heartbeatInterval = 25000; // 25 seconds\n\t\t\t\n\t\t\tfunction sendEnvelope(type, content, turn) {\n\t\t\t\tsocket.send(JSON.stringify({ version: protocolVersion, type: type, content: content, turn: turn }));\n\t\t\t}\n\t\t\t\n\t\t\tfunction setWaiting(waiting) {\n\t\t\t\twaitingForResponse = waiting;\n\t\t\t\tstopButton.classList.toggle('d-none', !waiting);\n\t\t\t}\n\t\t\t\n\t\t\tfunction markStopped(messageDiv) {\n\t\t\t\tconst stoppedNote = document.createElement('small');\n\t\t\t\tstoppedNote.className = 'text-muted d-block mt-1';\n\t\t\t\tstoppedNote.textContent = '(stopped)';\n\t\t\t\tmessageDiv.appendChild(stoppedNote);\n\t\t\t}\n\t\t\t\n\t\t\tfunction sourceTitle(source) {\n\t\t\t\treturn source.section ? source.name + ' – ' + source.section : source.name;\n\t\t\t}\n\t\t\t\n\t\t\tfunction sourceLink(source, text) {\n\t\t\t\tconst link = document.createElement('a');\n\t\t\t\tlink.href = '/cvSource?chunk=' + encodeURIComponent(source.chunk_id);\n\t\t\t\tlink.target = '_blank';\n\t\t\t\tlink.title = sourceTitle(source);\n\t\t\t\tlink.textContent = text;\n\t\t\t\treturn link;\n\t\t\t}\n\t\t\t\n\t\t\t// Turns the [n] citations of an answer into links to the cited CV section\n\t\t\tfunction linkCitations(messageDiv, sources) {\n\t\t\t\tif (sources.length === 0) {\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tconst byIndex = new Map(sources.map(source => [source.index, source]));\n\t\t\t\tconst walker = document.createTreeWalker(messageDiv, NodeFilter.SHOW_TEXT);\n\t\t\t\tconst textNodes = [];\n\t\t\t\twhile (walker.nextNode()) {\n\t\t\t\t\ttextNodes.push(walker.currentNode);\n\t\t\t\t}\n\t\t\t\ttextNodes.forEach(function(node) {\n\t\t\t\t\tif (node.parentElement.closest('a, code, pre')) {\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\tconst parts = node.textContent.split(/(\\[\\d+\\])/);\n\t\t\t\t\tif (parts.length === 1) {\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\tconst fragment = document.createDocumentFragment();\n\t\t\t\t\tparts.forEach(function(part) {\n\t\t\t\t\t\tconst match = part.match(/^\\[(\\d+)\\]$/);\n\t\t\t\t\t\tconst source = match && byIndex.get(Number(match[1]));\n\t\t\t\t\t\tfragment.appendChild(source ? sourceLink(source, part) : document.createTextNode(part));\n\t\t\t\t\t});\n\t\t\t\t\tnode.replaceWith(fragment);\n\t\t\t\t});\n\t\t\t}\n\t\t\t\n\t\t\t// Lists the CV excerpts an answer was based on below it\n\t\t\tfunction appendSources(messageDiv, sources) {\n\t\t\t\tif (sources.length === 0) {\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tconst sourcesDiv = document.createElement('small');\n\t\t\t\tsourcesDiv.className = 'text-muted d-block mt-1';\n\t\t\t\tsourcesDiv.appendChild(document.createTextNode('Sources: '));\n\t\t\t\tsources.forEach(function(source, i) {\n\t\t\t\t\tif (i > 0) {\n\t\t\t\t\t\tsourcesDiv.appendChild(document.createTextNode(', '));\n\t\t\t\t\t}\n\t\t\t\t\tsourcesDiv.appendChild(sourceLink(source, '[' + source.index + '] ' + sourceTitle(source)));\n\t\t\t\t});\n\t\t\t\tmessageDiv.appendChild(sourcesDiv);\n\t\t\t}\n\t\t\t\n\t\t\tfunction connectWebSocket() {\n\t\t\t\tconst protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';\n\t\t\t\tsocket = new WebSocket(protocol + '//' + window.location.host + '/ws?conversation=' + encodeURIComponent(conversationId));\n\t\t\t\t\n\t\t\t\tsocket.onopen = function() {\n\t\t\t\t\treconnectAttempts = 0;\n\t\t\t\t\tconsole.log('WebSocket connection established');\n\t\t\t\t\t\n\t\t\t\t\t// Keep proxies from closing an idle connection\n\t\t\t\t\theartbeatTimer = setInterval(() => sendEnvelope('heartbeat'), heartbeatInterval);\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onmessage = function(event) {\n\t\t\t\t\tlet message;\n\t\t\t\t\ttry {\n\t\t\t\t\t\tmessage = JSON.parse(event.data);\n\t\t\t\t\t} catch (e) {\n\t\t\t\t\t\tconsole.error('Malformed message from server:', event.data);\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Late replies to a question that was already stopped\n\t\t\t\t\tif (message.turn && message.turn !== currentTurn) {\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\tswitch (message.type) {\n\t\t\t\t\t\tcase 'token':\n\t\t\t\t\t\t\tappendToken(message.content);\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tcase 'context_sources':\n\t\t\t\t\t\t\tcurrentSources = message.sources || [];\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tcase 'employee_filter':\n\t\t\t\t\t\t\tshowFilter(message.content);\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tcase 'response_done':\n\t\t\t\t\t\t\tfinishResponse(message.truncated);\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tcase 'error':\n\t\t\t\t\t\t\tshowError(message.content);\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tcase 'heartbeat':\n\t\t\t\t\t\t\tbreak;\n\t\t\t\t\t\tdefault:\n\t\t\t\t\t\t\tconsole.warn('Unknown message type:', message.type);\n\t\t\t\t\t}\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onclose = function(event) {\n\t\t\t\t\tconsole.log('WebSocket closed:', event);\n\t\t\t\t\tclearInterval(heartbeatTimer);\n\t\t\t\t\tif (waitingForResponse) {\n\t\t\t\t\t\tfinishResponse();\n\t\t\t\t\t}\n\t\t\t\t\tattemptReconnect();\n\t\t\t\t};\n\t\t\t\t\n\t\t\t\tsocket.onerror = function(error) {\n\t\t\t\t\tconsole.error('WebSocket error:', error);\n\t\t\t\t};\n\t\t\t}\n\t\t\t\n\t\t\tfunction appendToken(content) {\n\t\t\t\t// Remove placeholder on first message\n\t\t\t\tif (chatPlaceholder) {\n\t\t\t\t\tchatPlaceholder.remove();\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\t// Create new assistant message if none exists\n\t\t\t\tif (!currentAssistantMessage) {\n\t\t\t\t\tcurrentAssistantMessage = document.createElement('div');\n\t\t\t\t\tcurrentAssistantMessage.className = 'assistant-message mb-3';\n\t\t\t\t\tchatMessages.appendChild(currentAssistantMessage);\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\t// Append content\n\t\t\t\tassistantMessageContent += content;\n\t\t\t\tcurrentAssistantMessage.innerHTML = marked.parse(assistantMessageContent);\n\t\t\t\tlinkCitations(currentAssistantMessage, currentSources);\n\t\t\t\t\n\t\t\t\t// Scroll to bottom\n\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t}\n\t\t\t\n\t\t\tfunction finishResponse(truncated) {\n\t\t\t\tif (currentAssistantMessage && truncated) {\n\t\t\t\t\tmarkStopped(currentAssistantMessage);\n\t\t\t\t}\n\t\t\t\tif (currentAssistantMessage) {\n\t\t\t\t\tappendSources(currentAssistantMessage, currentSources);\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tcurrentAssistantMessage = null;\n\t\t\t\tassistantMessageContent = '';\n\t\t\t\tcurrentSources = [];\n\t\t\t\tsetWaiting(false);\n\t\t\t\tchatInput.focus();\n\t\t\t}\n\t\t\t\n\t\t\t// Shows which employees the question was narrowed to\n\t\t\tfunction showFilter(description) {\n\t\t\t\tconst filterNote = document.createElement('small');\n\t\t\t\tfilterNote.className = 'text-muted d-block mb-2';\n\t\t\t\tfilterNote.textContent = 'Searching only employees matching: ' + description;\n\t\t\t\tchatMessages.appendChild(filterNote);\n\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t}\n\t\t\t\n\t\t\tfunction showError(content) {\n\t\t\t\tconst errorDiv = document.createElement('div');\n\t\t\t\terrorDiv.className = 'alert alert-danger mb-3';\n\t\t\t\terrorDiv.textContent = content;\n\t\t\t\tchatMessages.appendChild(errorDiv);\n\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t\t\n\t\t\t\t// Without a partial answer there will be no response_done\n\t\t\t\tif (!currentAssistantMessage) {\n\t\t\t\t\tfinishResponse();\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\tfunction attemptReconnect() {\n\t\t\t\tif (reconnectAttempts >= maxReconnectAttempts) {\n\t\t\t\t\tconsole.error('Max reconnect attempts reached');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tconst delay = reconnectDelayBase * Math.pow(2, reconnectAttempts);\n\t\t\t\treconnectAttempts++;\n\t\t\t\t\n\t\t\t\tconsole.log(`Attempting reconnect in ${delay}ms (attempt ${reconnectAttempts}/${maxReconnectAttempts})`);\n\t\t\t\t\n\t\t\t\tsetTimeout(() => {\n\t\t\t\t\tconsole.log('Reconnecting...');\n\t\t\t\t\tconnectWebSocket();\n\t\t\t\t}, delay);\n\t\t\t}\n\t\t\t\n\t\t\tfunction sendMessage() {\n\t\t\t\tconst message = chatInput.value.trim();\n\t\t\t\tif (message && socket && socket.readyState === WebSocket.OPEN) {\n\t\t\t\t\t// Remove placeholder\n\t\t\t\t\tif (chatPlaceholder) {\n\t\t\t\t\t\tchatPlaceholder.remove();\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Asking again stops the answer in progress\n\t\t\t\t\tif (waitingForResponse) {\n\t\t\t\t\t\tfinishResponse(true);\n\t\t\t\t\t}\n\t\t\t\t\t\n\t\t\t\t\t// Reset assistant message tracking\n\t\t\t\t\tcurrentAssistantMessage = null;\n\t\t\t\t\tassistantMessageContent = '';\n\t\t\t\t\tcurrentSources = [];\n\t\t\t\t\t\n\t\t\t\t\t// Add user message to UI\n\t\t\t\t\tconst userMessageDiv = document.createElement('div');\n\t\t\t\t\tuserMessageDiv.className = 'user-message mb-3 text-end';\n\t\t\t\t\tuserMessageDiv.innerHTML = marked.parse(`**You:** ${message}`);\n\t\t\t\t\tchatMessages.appendChild(userMessageDiv);\n\t\t\t\t\t\n\t\t\t\t\t// Send message via WebSocket\n\t\t\t\t\tcurrentTurn++;\n\t\t\t\t\tsendEnvelope('user_message', message, currentTurn);\n\t\t\t\t\tsetWaiting(true);\n\t\t\t\t\t\n\t\t\t\t\t// Clear input\n\t\t\t\t\tchatInput.value = '';\n\t\t\t\t\t\n\t\t\t\t\t// Scroll to bottom\n\t\t\t\t\tchatMessages.scrollTop = chatMessages.scrollHeight;\n\t\t\t\t\t\n\t\t\t\t\t// Focus input for next message\n\t\t\t\t\tchatInput.focus();\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\tfunction stopResponse() {\n\t\t\t\tif (waitingForResponse && socket && socket.readyState === WebSocket.OPEN) {\n\t\t\t\t\tsendEnvelope('cancel', undefined, currentTurn);\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\t// Send on button click\n\t\t\tsendButton.addEventListener('click', sendMessage);\n\t\t\t\n\t\t\t// Stop the answer in progress\n\t\t\tstopButton.addEventListener('click', stopResponse);\n\t\t\t\n\t\t\t// Send on Enter key\n\t\t\tchatInput.addEventListener('keypress', function(e) {\n\t\t\t\tif (e.key === 'Enter') {\n\t\t\t\t\tsendMessage();\n\t\t\t\t}\n\t\t\t});\n\t\t\t\n\t\t\t// Initialize WebSocket connection\n\t\t\tconnectWebSocket();\n\t\t\t\n\t\t\t// Focus input on load\n\t\t\tchatInput.focus();\n\t\t});\n\t</script><style>\n\t\t/* Improved chat styling */\n\t\t#chat-messages {\n\t\t\tdisplay: flex;\n\t\t\tflex-direction: column;\n\t\t\tgap: 1.5rem;\n\t\t\tfont-size: 1.2rem;\n\t\t\tline-height: 1.8;\n\t\t}\n\t\t\n\t\t.user-message div, .assistant-message div {\n\t\t\tpadding: 1.2rem;\n\t\t\tborder-radius: 12px;\n\t\t\tdisplay: inline-block;\n\t\t\tmax-width: 90%;\n\t\t}\n\t\t\n\t\t.user-message div {\n\t\t\tbackground: linear-gradient(to right, #6a11cb, #2575fc);\n\t\t\tcolor: white;\n\t\t\tborder-bottom-right-radius: 4px;\n\t\t}\n\t\t\n\t\t.assistant-message div {\n\t\t\tbackground-color: #f8f9fa;\n\t\t\tborder: 1px solid #dee2e6;\n\t\t\tborder-bottom-left-radius: 4px;\n\t\t}\n\t\t\n\t\t/* Markdown styling */\n\t\t#chat-messages p {\n\t\t\tmargin-bottom: 0.8rem;\n\t\t}\n\t\t\n\t\t#chat-messages h1, \n\t\t#chat-messages h2, \n\t\t#chat-messages h3 {\n\t\t\tmargin-top: 1.5rem;\n\t\t\tmargin-bottom: 1rem;\n\t\t}\n\t\t\n\t\t#chat-messages ul, \n\t\t#chat-messages ol {\n\t\t\tpadding-left: 2rem;\n\t\t\tmargin-bottom: 1rem;\n\t\t}\n\t\t\n\t\t#chat-messages li {\n\t\t\tmargin-bottom: 0.5rem;\n\t\t}\n\t\t\n\t\t#chat-messages code {\n\t\t\tbackground-color: #e9ecef;\n\t\t\tpadding: 0.3rem 0.5rem;\n\t\t\tborder-radius: 6px;\n\t\t\tfont-family: monospace;\n\t\t\tfont-size: 1.1rem;\n\t\t}\n\t\t\n\t\t#chat-messages pre {\n\t\t\tbackground-color: #2d2d2d;\n\t\t\tcolor: #f8f8f2;\n\t\t\tpadding: 1rem;\n\t\t\tborder-radius: 6px;\n\t\t\toverflow-x: auto;\n\t\t\tmargin-bottom: 1.5rem;\n\t\t}\n\t\t\n\t\t#chat-messages pre code {\n\t\t\tbackground-color: transparent;\n\t\t\tpadding: 0;\n\t\t}\n\t</style>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,94 @@
+package cvSource
+
+import (
+	"context"
+	"strings"
+
+	"teamforger/backend/core"
+)
+
+// GetCVSource returns a chunk with the markdown of its CV version. Chunks of
+// older versions are kept, so citations in old conversations still resolve.
+func GetCVSource(db core.DB, chunkId int) (core.CVSource, error) {
+	var source core.CVSource
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT cv_chunks.id, COALESCE(users.name, ''), cv_chunks.section, cv_chunks.chunk,
+			cv_versions.id, cv_versions.user_id, cv_versions.file_name, cv_versions.markdown, cv_versions.uploaded_at, cv_versions.is_active
+		FROM cv_chunks
+		JOIN cv_versions ON cv_versions.id = cv_chunks.version_id
+		JOIN users ON users.id = cv_chunks.user_id
+		WHERE cv_chunks.id = $1`,
+		chunkId).Scan(
+		&source.ChunkId, &source.EmployeeName, &source.Section, &source.Excerpt,
+		&source.Version.Id, &source.Version.UserId, &source.Version.FileName, &source.Version.Markdown, &source.Version.UploadedAt, &source.Version.IsActive)
+	if err != nil {
+		return source, err
+	}
+	source.Excerpt = stripChunkHeader(source.Excerpt)
+
+	if start, end, ok := locateExcerpt(source.Version.Markdown, source.Excerpt); ok {
+		source.Before = source.Version.Markdown[:start]
+		source.Cited = source.Version.Markdown[start:end]
+		source.After = source.Version.Markdown[end:]
+	}
+	return source, nil
+}
+
+// Lines shorter than this are too common to locate an excerpt by.
+const minAnchorLength = 8
+
+// locateExcerpt finds the lines of the markdown a chunk was cut from. Chunks
+// may start with a section prefix and end in the middle of a line, so it
+// looks for the first and the last line of the chunk found in the markdown.
+func locateExcerpt(markdown string, excerpt string) (int, int, bool) {
+	var lines []string
+	for _, line := range strings.Split(excerpt, "\n") {
+		if line = strings.TrimSpace(line); len([]rune(line)) >= minAnchorLength {
+			lines = append(lines, line)
+		}
+	}
+
+	start := -1
+	for _, line := range lines {
+		index := strings.Index(markdown, line)
+		if _, rest, found := strings.Cut(line, ": "); index == -1 && found && len([]rune(rest)) >= minAnchorLength {
+			// Without a prefix like "Worked in project: "
+			index = strings.Index(markdown, rest)
+		}
+		if index != -1 {
+			start = strings.LastIndex(markdown[:index], "\n") + 1
+			break
+		}
+	}
+	if start == -1 {
+		return 0, 0, false
+	}
+
+	end := len(markdown)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if index := strings.LastIndex(markdown[start:], lines[i]); index != -1 {
+			end = start + index + len(lines[i])
+			if newline := strings.Index(markdown[end:], "\n"); newline != -1 {
+				end += newline
+			} else {
+				end = len(markdown)
+			}
+			break
+		}
+	}
+	return start, end, true
+}
+
+// stripChunkHeader removes the "Employee:" and "Section:" lines every chunk
+// starts with, the page shows them already.
+func stripChunkHeader(chunk string) string {
+	for _, prefix := range []string{"Employee: ", "Section: "} {
+		if strings.HasPrefix(chunk, prefix) {
+			if end := strings.Index(chunk, "\n"); end != -1 {
+				chunk = chunk[end+1:]
+			}
+		}
+	}
+	return strings.TrimLeft(chunk, "\n")
+}
@@ -0,0 +1,116 @@
+package cvVersions
+
+import (
+	"context"
+
+	"teamforger/backend/core"
+	"teamforger/backend/pages/uploadCV"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetEmployee returns the user whose CV history is shown.
+func GetEmployee(db core.DB, userId int) (core.User, error) {
+	var employee core.User
+	err := db.QueryRow(
+		context.Background(),
+		"SELECT id, COALESCE(name, ''), email FROM users WHERE id = $1",
+		userId).Scan(&employee.Id, &employee.Name, &employee.Email)
+	return employee, err
+}
+
+// ListCVVersions returns the versions of a CV, newest first, without the
+// original files.
+func ListCVVersions(db core.DB, userId int) ([]core.CVVersion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT cv_versions.id, cv_versions.user_id, file_name, uploaded_at, COALESCE(uploaded_by, 0), COALESCE(uploader.name, ''), is_active
+		FROM cv_versions LEFT JOIN users uploader ON uploader.id = cv_versions.uploaded_by
+		WHERE cv_versions.user_id = $1
+		ORDER BY cv_versions.id DESC`,
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []core.CVVersion
+	for rows.Next() {
+		var version core.CVVersion
+		if err := rows.Scan(&version.Id, &version.UserId, &version.FileName, &version.UploadedAt, &version.UploadedBy, &version.UploaderName, &version.IsActive); err != nil {
+			return versions, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetCVVersion returns a version including its markdown and original file.
+func GetCVVersion(db core.DB, versionId int) (core.CVVersion, error) {
+	var version core.CVVersion
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT cv_versions.id, cv_versions.user_id, file_name, original, markdown, uploaded_at, COALESCE(uploaded_by, 0), COALESCE(uploader.name, ''), is_active
+		FROM cv_versions LEFT JOIN users uploader ON uploader.id = cv_versions.uploaded_by
+		WHERE cv_versions.id = $1`,
+		versionId).Scan(
+		&version.Id, &version.UserId, &version.FileName, &version.Original, &version.Markdown, &version.UploadedAt, &version.UploadedBy, &version.UploaderName, &version.IsActive)
+	return version, err
+}
+
+// RestoreCVVersion makes an older version the active one again. Its chunks
+// and projects were kept, so it is searchable right away. The extracted
+// skills are not versioned, they are extracted again from the restored CV,
+// manual skills stay.
+func RestoreCVVersion(db core.DB, versionId int, extractor *uploadCV.SkillExtractor, chunker *uploadCV.Chunker) error {
+	// The markdown of a version never changes, so the slow extraction can run
+	// before the transaction and hold no lock
+	var markdown string
+	err := db.QueryRow(context.Background(), "SELECT markdown FROM cv_versions WHERE id = $1", versionId).Scan(&markdown)
+	if err != nil {
+		return err
+	}
+	skills, source := uploadCV.ExtractCVSkills(extractor, chunker, markdown)
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	var userId int
+	err = tx.QueryRow(
+		context.Background(),
+		"SELECT user_id FROM cv_versions WHERE id = $1 FOR UPDATE",
+		versionId).Scan(&userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE cv_versions SET is_active = FALSE WHERE user_id = $1 AND is_active", userId)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(context.Background(), "UPDATE cv_versions SET is_active = TRUE WHERE id = $1", versionId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(context.Background(), "UPDATE users SET cv = $1 WHERE id = $2", markdown, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := uploadCV.ReplaceExtractedSkills(tx, userId, skills, source); err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
@@ -0,0 +1,70 @@
+package employees
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"teamforger/backend/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidAttributes = errors.New("invalid employee attributes")
+
+// Longest department or location accepted, anything longer is a typo.
+const maxAttributeLength = 100
+
+// ListEmployees returns every user with their attributes, by name.
+func ListEmployees(db core.DB) ([]core.Employee, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, COALESCE(name, ''), email, isAdmin, COALESCE(cv, '') <> '', department, location, seniority, employment_status
+		FROM users
+		ORDER BY lower(COALESCE(name, '')), email`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var employees []core.Employee
+	for rows.Next() {
+		var employee core.Employee
+		err := rows.Scan(
+			&employee.Id, &employee.Name, &employee.Email, &employee.IsAdmin, &employee.HasCV,
+			&employee.Department, &employee.Location, &employee.Seniority, &employee.EmploymentStatus)
+		if err != nil {
+			return employees, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+// UpdateAttributes stores the attributes of an employee.
+func UpdateAttributes(db core.DB, userId int, attributes core.EmployeeAttributes) error {
+	attributes.Department = strings.Join(strings.Fields(attributes.Department), " ")
+	attributes.Location = strings.Join(strings.Fields(attributes.Location), " ")
+	if len([]rune(attributes.Department)) > maxAttributeLength || len([]rune(attributes.Location)) > maxAttributeLength {
+		return ErrInvalidAttributes
+	}
+	if attributes.Seniority != "" && !slices.Contains(core.SeniorityLevels, attributes.Seniority) {
+		return ErrInvalidAttributes
+	}
+	if !slices.Contains(core.EmploymentStatuses, attributes.EmploymentStatus) {
+		return ErrInvalidAttributes
+	}
+
+	tag, err := db.Exec(
+		context.Background(),
+		"UPDATE users SET department = $1, location = $2, seniority = $3, employment_status = $4 WHERE id = $5",
+		attributes.Department, attributes.Location, attributes.Seniority, attributes.EmploymentStatus, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
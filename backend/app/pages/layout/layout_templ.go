@@ -93,7 +93,7 @@ func Base(isLoggedIn bool, user core.User, content templ.Component) templ.Compon
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</div></main><script src=\"https://cdn.jsdelivr.net/npm/bootstrap@5.3.6/dist/js/bootstrap.bundle.min.js\"></script><script>\n            // Message mappings\n            const successMessages = {\n                accountCreated: \"Account created successfully!\",\n                welcomeBack: \"Welcome back!\",\n                signedOut: \"You have been signed out.\",\n                CVConverted: \"CV uploaded and converted successfully!\"\n            };\n            \n            const errorMessages = {\n                databaseError: \"Database error. Please try again later.\",\n                cookieError: \"Cookie error. Please sign in again.\",\n                tokenGenerationFailed: \"Failed to generate tokens. Please try again.\",\n                tokenUpdateFailed: \"Failed to update tokens. Please try again.\",\n                emailNotFound: \"Email not found.\",\n                wrongPassword: \"Incorrect password.\",\n                duplicateEmail: \"Email already in use.\",\n                createAccountError: \"Failed to create account. Please try again.\",\n                fileUploadError: \"File upload failed. Please try again.\",\n                docxConversionError: \"Failed to convert DOCX file.\",\n                cvStorageFailed: \"Failed to store CV. Please try again.\",\n                notAdmin: \"You must be an administrator to access this page.\",\n                tokenClearFailed: \"Failed to clear session tokens.\"\n            };\n            \n            document.addEventListener('DOMContentLoaded', function() {\n                const urlParams = new URLSearchParams(window.location.search);\n                const notification = document.getElementById('notification');\n                const messageSpan = document.getElementById('notification-message');\n                const alertDiv = notification.querySelector('.alert');\n                \n                // Check for success message\n                const successParam = urlParams.get('success');\n                if (successParam && successMessages[successParam]) {\n                    messageSpan.textContent = successMessages[successParam];\n                    alertDiv.classList.add('alert-success');\n                    notification.style.display = 'block';\n                    \n                    // Auto-hide after 5 seconds\n                    setTimeout(() => {\n                        notification.style.display = 'none';\n                    }, 5000);\n                }\n                \n                // Check for error message\n                const errorParam = urlParams.get('error');\n                if (errorParam && errorMessages[errorParam]) {\n                    messageSpan.textContent = errorMessages[errorParam];\n                    alertDiv.classList.add('alert-danger');\n                    notification.style.display = 'block';\n                }\n                \n                // Close button handler\n                notification.querySelector('.btn-close').addEventListener('click', function() {\n                    notification.style.display = 'none';\n                });\n                \n                // Remove query params from URL without reloading\n                const cleanUrl = window.location.protocol + \"//\" + window.location.host + window.location.pathname;\n                window.history.replaceState({}, document.title, cleanUrl);\n            });\n        </script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</div></main><script src=\"https://cdn.jsdelivr.net/npm/bootstrap@5.3.6/dist/js/bootstrap.bundle.min.js\"></script><script>\n            // Message mappings\n            const successMessages = {\n                accountCreated: \"Account created successfully!\",\n                welcomeBack: \"Welcome back!\",\n                signedOut: \"You have been signed out.\",\n                CVConverted: \"CV uploaded and converted successfully!\",\n                conversationRenamed: \"Conversation renamed.\",\n                conversationDeleted: \"Conversation deleted.\",\n                retrievalSaved: \"Retrieval settings saved.\",\n                cvVersionRestored: \"CV version restored.\",\n                skillSaved: \"Skill saved.\",\n                skillDeleted: \"Skill removed.\",\n                taxonomyImported: \"Skill taxonomy imported.\",\n                skillCreated: \"Skill added to the taxonomy.\",\n                skillAliasSaved: \"Alias saved.\",\n                skillAliasDeleted: \"Alias removed.\",\n                skillsMerged: \"Skills merged.\",\n                employeeSaved: \"Employee saved.\"\n            };\n            \n            const errorMessages = {\n                databaseError: \"Database error. Please try again later.\",\n                cookieError: \"Cookie error. Please sign in again.\",\n                tokenGenerationFailed: \"Failed to generate tokens. Please try again.\",\n                tokenUpdateFailed: \"Failed to update tokens. Please try again.\",\n                emailNotFound: \"Email not found.\",\n                wrongPassword: \"Incorrect password.\",\n                duplicateEmail: \"Email already in use.\",\n                createAccountError: \"Failed to create account. Please try again.\",\n                fileUploadError: \"File upload failed. Please try again.\",\n                unsupportedFileType: \"Unsupported file type. Please upload a DOCX, PDF, ODT, HTML, Markdown or plain text CV.\",\n                cvStorageFailed: \"Failed to store CV. Please try again.\",\n                cvJobNotFound: \"CV upload not found.\",\n                cvVersionsError: \"Failed to load the CV history. Please try again.\",\n                cvVersionNotFound: \"CV version not found.\",\n                cvVersionRestoreFailed: \"Failed to restore the CV version.\",\n                cvSourceNotFound: \"The cited CV excerpt no longer exists.\",\n                skillsError: \"Failed to load your skills. Please try again.\",\n                skillNotFound: \"Skill not found.\",\n                skillEmpty: \"Skill name is required.\",\n                skillTooLong: \"Skill name is too long.\",\n                badSkillYears: \"Years of experience must be a number between 0 and 60.\",\n                badSkillYear: \"Last used must be a year no later than this one.\",\n                skillSaveFailed: \"Failed to save the skill. You may already have a skill with that name.\",\n                taxonomyError: \"Failed to load the skill taxonomy. Please try again.\",\n                unsupportedTaxonomyFile: \"Unsupported file type. Please upload a CSV or YAML file.\",\n                taxonomyImportInvalid: \"The file could not be imported. Check its format, and that no alias is the name of another skill.\",\n                taxonomySkillNotFound: \"Skill not found in the taxonomy.\",\n                skillExists: \"This skill, or an alias with that name, already exists.\",\n                aliasIsSkill: \"This alias is the name of a skill. Merge the two skills instead.\",\n                sameSkill: \"Choose two different skills to merge.\",\n                taxonomySaveFailed: \"Failed to update the skill taxonomy.\",\n                projectsError: \"Failed to search the project history. Please try again.\",\n                employeesError: \"Failed to load the employees. Please try again.\",\n                employeeNotFound: \"Employee not found.\",\n                employeeAttributesInvalid: \"Invalid employee attributes. Department and location may have at most 100 characters.\",\n                employeeSaveFailed: \"Failed to save the employee.\",\n                notAdmin: \"You must be an administrator to access this page.\",\n                tokenClearFailed: \"Failed to clear session tokens.\",\n                conversationError: \"Failed to load conversations. Please try again.\",\n                conversationNotFound: \"Conversation not found.\",\n                conversationRenameFailed: \"Failed to rename the conversation.\",\n                conversationDeleteFailed: \"Failed to delete the conversation.\",\n                retrievalInvalid: \"Invalid retrieval settings, check the allowed ranges.\",\n                retrievalSaveFailed: \"Failed to save the retrieval settings.\"\n            };\n            \n            document.addEventListener('DOMContentLoaded', function() {\n                const urlParams = new URLSearchParams(window.location.search);\n                const notification = document.getElementById('notification');\n                const messageSpan = document.getElementById('notification-message');\n                const alertDiv = notification.querySelector('.alert');\n                \n                // Check for success message\n                const successParam = urlParams.get('success');\n                if (successParam && successMessages[successParam]) {\n                    messageSpan.textContent = successMessages[successParam];\n                    alertDiv.classList.add('alert-success');\n                    notification.style.display = 'block';\n                    \n                    // Auto-hide after 5 seconds\n                    setTimeout(() => {\n                        notification.style.display = 'none';\n                    }, 5000);\n                }\n                \n                // Check for error message\n                const errorParam = urlParams.get('error');\n                if (errorParam && errorMessages[errorParam]) {\n                    messageSpan.textContent = errorMessages[errorParam];\n                    alertDiv.classList.add('alert-danger');\n                    notification.style.display = 'block';\n                }\n                \n                // Close button handler\n                notification.querySelector('.btn-close').addEventListener('click', function() {\n                    notification.style.display = 'none';\n                });\n                \n                // Remove the notification params from URL without reloading\n                urlParams.delete('success');\n                urlParams.delete('error');\n                const remainingParams = urlParams.toString();\n                const cleanUrl = window.location.protocol + \"//\" + window.location.host + window.location.pathname + (remainingParams ? \"?\" + remainingParams : \"\");\n                window.history.replaceState({}, document.title, cleanUrl);\n            });\n        </script></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"context"
+
+	"teamforger/backend/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func ListSkills(db core.DB, userId int) ([]core.EmployeeSkill, error) {
+	rows, err := db.Query(
+		context.Background(),
+		"SELECT id, user_id, skill, years_experience::float8, last_used_year, source, updated_at FROM employee_skills WHERE user_id = $1 ORDER BY lower(skill)",
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skills []core.EmployeeSkill
+	for rows.Next() {
+		var skill core.EmployeeSkill
+		if err := rows.Scan(&skill.Id, &skill.UserId, &skill.Skill, &skill.YearsExperience, &skill.LastUsedYear, &skill.Source, &skill.UpdatedAt); err != nil {
+			return skills, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
+
+// AddSkill adds a skill entered by the employee, or takes over the extracted
+// one of the same name. Aliases like "k8s" are stored as the canonical skill.
+func AddSkill(db core.DB, userId int, skill core.EmployeeSkill) error {
+	taxonomy, err := core.LoadSkillTaxonomy(db)
+	if err != nil {
+		return err
+	}
+	skill.Skill, skill.SkillId = taxonomy.Canonical(skill.Skill)
+
+	_, err = db.Exec(
+		context.Background(),
+		`INSERT INTO employee_skills (user_id, skill, skill_id, years_experience, last_used_year, source) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, lower(skill)) DO UPDATE
+		SET skill = EXCLUDED.skill, skill_id = EXCLUDED.skill_id, years_experience = EXCLUDED.years_experience, last_used_year = EXCLUDED.last_used_year, source = EXCLUDED.source, updated_at = now()`,
+		userId, skill.Skill, skill.SkillId, skill.YearsExperience, skill.LastUsedYear, core.SkillSourceManual)
+	return err
+}
+
+// UpdateSkill stores the employee's correction of a skill. From then on the
+// skill is manual and kept when a new CV is uploaded.
+func UpdateSkill(db core.DB, skillId int, userId int, skill core.EmployeeSkill) error {
+	taxonomy, err := core.LoadSkillTaxonomy(db)
+	if err != nil {
+		return err
+	}
+	skill.Skill, skill.SkillId = taxonomy.Canonical(skill.Skill)
+
+	tag, err := db.Exec(
+		context.Background(),
+		`UPDATE employee_skills SET skill = $1, skill_id = $2, years_experience = $3, last_used_year = $4, source = $5, updated_at = now()
+		WHERE id = $6 AND user_id = $7`,
+		skill.Skill, skill.SkillId, skill.YearsExperience, skill.LastUsedYear, core.SkillSourceManual, skillId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteSkill(db core.DB, skillId int, userId int) error {
+	tag, err := db.Exec(
+		context.Background(),
+		"DELETE FROM employee_skills WHERE id = $1 AND user_id = $2",
+		skillId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package projectSearch
+
+import (
+	"context"
+	"strings"
+
+	"teamforger/backend/core"
+)
+
+const maxProjectResults = 200
+
+// SearchProjects finds projects of the active CVs. A technology also matches
+// its aliases and the skills below it in the taxonomy.
+func SearchProjects(db core.DB, filter core.ProjectFilter) ([]core.EmployeeProject, error) {
+	technologies := []string{}
+	if technology := core.NormalizeSkillName(filter.Technology); technology != "" {
+		technologies = append(technologies, strings.ToLower(technology))
+		taxonomy, err := core.LoadSkillTaxonomy(db)
+		if err != nil {
+			return nil, err
+		}
+		// Descendants include the skill itself. Aliases find technologies
+		// stored before the alias was added to the taxonomy.
+		if skill, ok := taxonomy.Resolve(technology); ok {
+			for _, descendant := range taxonomy.Descendants(skill.Id) {
+				technologies = append(technologies, strings.ToLower(descendant.Name))
+				for _, alias := range descendant.Aliases {
+					technologies = append(technologies, strings.ToLower(alias))
+				}
+			}
+		}
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT employee_projects.id, employee_projects.user_id, employee_projects.version_id, section, employee_projects.name, client, role,
+			start_date, end_date, is_current, team_size, technologies, COALESCE(users.name, '')
+		FROM employee_projects
+		JOIN cv_versions ON cv_versions.id = employee_projects.version_id AND cv_versions.is_active
+		JOIN users ON users.id = employee_projects.user_id
+		WHERE ($1 = '' OR client ILIKE '%' || $1 || '%' ESCAPE '\')
+		AND (cardinality($2::text[]) = 0 OR EXISTS (SELECT 1 FROM unnest(technologies) AS technology WHERE lower(technology) = ANY($2)))
+		AND ($3 = '' OR role ILIKE '%' || $3 || '%' ESCAPE '\')
+		AND ($4 = 0 OR is_current OR COALESCE(end_date, start_date) >= date_trunc('month', now()) - make_interval(years => $4))
+		ORDER BY is_current DESC, COALESCE(end_date, start_date) DESC NULLS LAST, users.name
+		LIMIT $5`,
+		likePattern(filter.Client), technologies, likePattern(filter.Role), filter.WithinYears, maxProjectResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []core.EmployeeProject
+	for rows.Next() {
+		var project core.EmployeeProject
+		err := rows.Scan(
+			&project.Id, &project.UserId, &project.VersionId, &project.Section, &project.Name, &project.Client, &project.Role,
+			&project.StartDate, &project.EndDate, &project.IsCurrent, &project.TeamSize, &project.Technologies, &project.EmployeeName)
+		if err != nil {
+			return projects, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+// likePattern escapes the wildcards of ILIKE in user input.
+func likePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSpace(value))
+}
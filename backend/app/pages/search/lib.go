@@ -0,0 +1,93 @@
+package search
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"teamforger/backend/core"
+)
+
+var ErrInvalidFilter = errors.New("invalid employee filter")
+
+// SearchResult is a CV chunk found by the /search endpoint.
+type SearchResult struct {
+	ChunkId    int     `json:"chunk_id"`
+	EmployeeId int     `json:"employee_id"`
+	Name       string  `json:"name"`
+	Chunk      string  `json:"chunk"`
+	Similarity float64 `json:"similarity"`
+	Score      float64 `json:"score"`
+}
+
+// ParseFilter reads the employee filter of a search from its query
+// parameters, each of which may repeat: department, location, seniority and
+// status. Statuses replace the ones of base, admins=true includes the
+// administrators.
+func ParseFilter(query url.Values, base core.EmployeeFilter) (core.EmployeeFilter, error) {
+	filter := base
+	if values := nonEmpty(query["department"]); len(values) > 0 {
+		filter.Departments = values
+	}
+	if values := nonEmpty(query["location"]); len(values) > 0 {
+		filter.Locations = values
+	}
+	if values := nonEmpty(query["seniority"]); len(values) > 0 {
+		for _, value := range values {
+			if !slices.Contains(core.SeniorityLevels, value) {
+				return base, ErrInvalidFilter
+			}
+		}
+		filter.Seniorities = values
+	}
+	if values := nonEmpty(query["status"]); len(values) > 0 {
+		for _, value := range values {
+			if !slices.Contains(core.EmploymentStatuses, value) {
+				return base, ErrInvalidFilter
+			}
+		}
+		filter.Statuses = values
+	}
+	if query.Get("admins") == "true" {
+		filter.IncludeAdmins = true
+	}
+	return filter, nil
+}
+
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// Search returns the chunks best matching a query within the employees of
+// config.Filter.
+func Search(db core.DB, embedder core.Embedder, query string, config core.RetrievalConfig) ([]SearchResult, error) {
+	queryEmbedding, err := core.GetEmbedding(embedder, query)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := core.SelectCVChunks(db, queryEmbedding, query, config)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for _, chunk := range chunks {
+		results = append(results, SearchResult{
+			ChunkId:    chunk.Id,
+			EmployeeId: chunk.UserId,
+			Name:       chunk.Name,
+			Chunk:      chunk.Chunk,
+			Similarity: 1 - chunk.Distance,
+			Score:      chunk.Score,
+		})
+	}
+	return results, nil
+}
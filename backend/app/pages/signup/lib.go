@@ -3,12 +3,11 @@ package signup
 import (
 	"fmt"
 	"context"
-	"github.com/jackc/pgx/v5"
 	"teamforger/backend/core"
 )
 
-func CreateUser (conn *pgx.Conn, user core.User) error {
-	userCount, err := core.CountUsers(conn)
+func CreateUser (db core.DB, user core.User) error {
+	userCount, err := core.CountUsers(db)
 	if err != nil {
 		fmt.Println("Could not count the users. Error: ")
 		return err
@@ -21,7 +20,7 @@ func CreateUser (conn *pgx.Conn, user core.User) error {
 	}
 
 	// Start a transaction
-	tx, err := conn.Begin(context.Background())
+	tx, err := db.Begin(context.Background())
 	if err != nil {
 	    return err
 	}
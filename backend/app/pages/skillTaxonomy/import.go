@@ -0,0 +1,204 @@
+package skillTaxonomy
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"teamforger/backend/core"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnsupportedTaxonomyFormat = errors.New("unsupported taxonomy file, use .csv, .yaml or .yml")
+
+// TaxonomyEntry is one skill of an import file. A CSV file has the columns
+// skill, aliases and parent with the aliases separated by "|". A YAML file
+// is a list of entries:
+//
+//   - skill: Kubernetes
+//     aliases: [k8s, kube]
+//     parent: Container orchestration
+type TaxonomyEntry struct {
+	Skill   string   `yaml:"skill"`
+	Aliases []string `yaml:"aliases"`
+	Parent  string   `yaml:"parent"`
+}
+
+// ImportResult counts what an import added or changed.
+type ImportResult struct {
+	Skills  int
+	Aliases int
+}
+
+func ParseTaxonomyFile(data []byte, fileName string) ([]TaxonomyEntry, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return parseTaxonomyCSV(data)
+	case ".yaml", ".yml":
+		return parseTaxonomyYAML(data)
+	}
+	return nil, ErrUnsupportedTaxonomyFormat
+}
+
+func parseTaxonomyCSV(data []byte) ([]TaxonomyEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid taxonomy CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["skill"]; !ok {
+		return nil, fmt.Errorf("invalid taxonomy CSV: the header needs a skill column")
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []TaxonomyEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid taxonomy CSV: %w", err)
+		}
+		entry := TaxonomyEntry{Skill: field(record, "skill"), Parent: field(record, "parent")}
+		if aliases := field(record, "aliases"); aliases != "" {
+			entry.Aliases = strings.Split(aliases, "|")
+		}
+		if err := checkEntry(entry); err != nil {
+			return nil, fmt.Errorf("invalid taxonomy CSV line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseTaxonomyYAML(data []byte) ([]TaxonomyEntry, error) {
+	var entries []TaxonomyEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid taxonomy YAML: %w", err)
+	}
+	for i, entry := range entries {
+		if err := checkEntry(entry); err != nil {
+			return nil, fmt.Errorf("invalid taxonomy YAML entry %d: %w", i+1, err)
+		}
+	}
+	return entries, nil
+}
+
+func checkEntry(entry TaxonomyEntry) error {
+	if core.NormalizeSkillName(entry.Skill) == "" {
+		return errors.New("the skill is missing")
+	}
+	if strings.EqualFold(core.NormalizeSkillName(entry.Skill), core.NormalizeSkillName(entry.Parent)) {
+		return fmt.Errorf("%q cannot be its own parent", entry.Skill)
+	}
+	return nil
+}
+
+// ImportTaxonomy adds the skills, aliases and parents of the entries to the
+// taxonomy. The file wins over what is stored: an alias moves to the skill
+// the file puts it under, and a parent is replaced. Nothing is removed.
+func ImportTaxonomy(db core.DB, entries []TaxonomyEntry) (ImportResult, error) {
+	var result ImportResult
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return result, err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	for _, entry := range entries {
+		skillId, created, err := ensureSkill(tx, entry.Skill)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			result.Skills++
+		}
+
+		if parent := core.NormalizeSkillName(entry.Parent); parent != "" {
+			parentId, created, err := ensureSkill(tx, parent)
+			if err != nil {
+				return result, err
+			}
+			if created {
+				result.Skills++
+			}
+			if _, err := tx.Exec(context.Background(), "UPDATE skills SET parent_id = $1 WHERE id = $2", parentId, skillId); err != nil {
+				return result, err
+			}
+		}
+
+		for _, alias := range entry.Aliases {
+			alias = core.NormalizeSkillName(alias)
+			if alias == "" || strings.EqualFold(alias, core.NormalizeSkillName(entry.Skill)) {
+				continue
+			}
+			tag, err := tx.Exec(
+				context.Background(),
+				`INSERT INTO skill_aliases (skill_id, alias) VALUES ($1, $2)
+				ON CONFLICT (lower(alias)) DO UPDATE SET skill_id = EXCLUDED.skill_id
+				WHERE skill_aliases.skill_id <> EXCLUDED.skill_id`,
+				skillId, alias)
+			if err != nil {
+				return result, err
+			}
+			result.Aliases += int(tag.RowsAffected())
+		}
+	}
+
+	taxonomy, err := core.LoadSkillTaxonomy(tx)
+	if err != nil {
+		return result, err
+	}
+	if err := taxonomy.Validate(); err != nil {
+		return result, err
+	}
+
+	if err := core.LinkEmployeeSkills(tx); err != nil {
+		return result, err
+	}
+
+	return result, tx.Commit(context.Background())
+}
+
+// ensureSkill returns the id of the skill with the name, creating it when
+// needed.
+func ensureSkill(db core.DB, name string) (int, bool, error) {
+	name = core.NormalizeSkillName(name)
+
+	var skillId int
+	err := db.QueryRow(context.Background(), "SELECT id FROM skills WHERE lower(name) = lower($1)", name).Scan(&skillId)
+	if err == nil {
+		return skillId, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, err
+	}
+
+	err = db.QueryRow(context.Background(), "INSERT INTO skills (name) VALUES ($1) RETURNING id", name).Scan(&skillId)
+	return skillId, true, err
+}
@@ -0,0 +1,252 @@
+package skillTaxonomy
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"teamforger/backend/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSkillExists  = errors.New("a skill with this name already exists")
+	ErrAliasIsSkill = errors.New("the alias is the name of a skill")
+	ErrSameSkill    = errors.New("a skill cannot be merged into itself")
+)
+
+// The most frequent unknown names are shown, the long tail is mostly noise.
+const maxUnlinkedSkills = 100
+
+// ListSkillUsage returns the taxonomy with the number of employees per skill.
+func ListSkillUsage(db core.DB) ([]core.SkillUsage, error) {
+	taxonomy, err := core.LoadSkillTaxonomy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		"SELECT skill_id, count(DISTINCT user_id) FROM employee_skills WHERE skill_id IS NOT NULL GROUP BY skill_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	employees := map[int]int{}
+	for rows.Next() {
+		var skillId, count int
+		if err := rows.Scan(&skillId, &count); err != nil {
+			return nil, err
+		}
+		employees[skillId] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var usage []core.SkillUsage
+	for _, skill := range taxonomy.Skills() {
+		entry := core.SkillUsage{Skill: skill, Employees: employees[skill.Id]}
+		if skill.ParentId != nil {
+			if parent, ok := taxonomy.Get(*skill.ParentId); ok {
+				entry.ParentName = parent.Name
+			}
+		}
+		usage = append(usage, entry)
+	}
+	return usage, nil
+}
+
+// ListUnlinkedSkills returns the employee skill names the taxonomy does not
+// know yet, the most common first.
+func ListUnlinkedSkills(db core.DB) ([]core.UnlinkedSkill, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT min(skill), count(DISTINCT user_id) AS employees FROM employee_skills
+		WHERE skill_id IS NULL
+		GROUP BY lower(skill)
+		ORDER BY employees DESC, lower(skill)
+		LIMIT $1`,
+		maxUnlinkedSkills)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skills []core.UnlinkedSkill
+	for rows.Next() {
+		var skill core.UnlinkedSkill
+		if err := rows.Scan(&skill.Name, &skill.Employees); err != nil {
+			return skills, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
+
+// CreateSkill adds a canonical skill and links the employee skills of that
+// name to it.
+func CreateSkill(db core.DB, name string, parentId *int) error {
+	name = core.NormalizeSkillName(name)
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	taxonomy, err := core.LoadSkillTaxonomy(tx)
+	if err != nil {
+		return err
+	}
+	if _, ok := taxonomy.Resolve(name); ok {
+		return ErrSkillExists
+	}
+	if parentId != nil {
+		if _, ok := taxonomy.Get(*parentId); !ok {
+			return pgx.ErrNoRows
+		}
+	}
+
+	if _, err := tx.Exec(context.Background(), "INSERT INTO skills (name, parent_id) VALUES ($1, $2)", name, parentId); err != nil {
+		return err
+	}
+
+	if err := core.LinkEmployeeSkills(tx); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// AddAlias makes a name an alias of a skill, moving it away from the skill
+// it was an alias of before.
+func AddAlias(db core.DB, skillId int, alias string) error {
+	alias = core.NormalizeSkillName(alias)
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	taxonomy, err := core.LoadSkillTaxonomy(tx)
+	if err != nil {
+		return err
+	}
+	if _, ok := taxonomy.Get(skillId); !ok {
+		return pgx.ErrNoRows
+	}
+	for _, skill := range taxonomy.Skills() {
+		if strings.EqualFold(skill.Name, alias) {
+			return ErrAliasIsSkill
+		}
+	}
+
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO skill_aliases (skill_id, alias) VALUES ($1, $2)
+		ON CONFLICT (lower(alias)) DO UPDATE SET skill_id = EXCLUDED.skill_id, alias = EXCLUDED.alias`,
+		skillId, alias)
+	if err != nil {
+		return err
+	}
+
+	if err := core.LinkEmployeeSkills(tx); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// DeleteAlias removes an alias. Employee skills already renamed by it keep
+// the canonical name.
+func DeleteAlias(db core.DB, skillId int, alias string) error {
+	tag, err := db.Exec(
+		context.Background(),
+		"DELETE FROM skill_aliases WHERE skill_id = $1 AND lower(alias) = lower($2)",
+		skillId, alias)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// MergeSkills folds a duplicate skill into another one: its name and aliases
+// become aliases of the target, its children and employees move over, and
+// employees having both keep a single entry.
+func MergeSkills(db core.DB, fromId int, intoId int) error {
+	if fromId == intoId {
+		return ErrSameSkill
+	}
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	taxonomy, err := core.LoadSkillTaxonomy(tx)
+	if err != nil {
+		return err
+	}
+	from, ok := taxonomy.Get(fromId)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	into, ok := taxonomy.Get(intoId)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+
+	// Merging a skill into one below it would make the target its own
+	// ancestor, it takes the place of the merged skill instead.
+	for _, descendant := range taxonomy.Descendants(from.Id) {
+		if descendant.Id == into.Id {
+			if _, err := tx.Exec(context.Background(), "UPDATE skills SET parent_id = $1 WHERE id = $2", from.ParentId, into.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	queries := []string{
+		"UPDATE skill_aliases SET skill_id = $2 WHERE skill_id = $1",
+		"UPDATE skills SET parent_id = $2 WHERE parent_id = $1 AND id <> $2",
+		"UPDATE employee_skills SET skill_id = $2 WHERE skill_id = $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(context.Background(), query, from.Id, into.Id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(context.Background(), "DELETE FROM skills WHERE id = $1", from.Id); err != nil {
+		return err
+	}
+
+	// The old name stays known, CVs still use it.
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO skill_aliases (skill_id, alias) VALUES ($1, $2)
+		ON CONFLICT (lower(alias)) DO UPDATE SET skill_id = EXCLUDED.skill_id`,
+		into.Id, from.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := core.LinkEmployeeSkills(tx); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
@@ -0,0 +1,250 @@
+package uploadCV
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"teamforger/backend/core"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// EnqueueCVJob stores an uploaded file for the workers. Jobs of the same user
+// that have not started yet are superseded by the new upload.
+func EnqueueCVJob(db core.DB, userId int, fileName string, fileData []byte) (int, error) {
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return -1, err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(
+		context.Background(),
+		"UPDATE cv_jobs SET status = $1, error = $2, file_data = NULL, updated_at = now() WHERE user_id = $3 AND status = $4",
+		core.CVJobFailed, "Superseded by a newer upload.", userId, core.CVJobQueued)
+	if err != nil {
+		return -1, err
+	}
+
+	var jobId int
+	err = tx.QueryRow(
+		context.Background(),
+		"INSERT INTO cv_jobs (user_id, file_name, file_data) VALUES ($1, $2, $3) RETURNING id",
+		userId, fileName, fileData).Scan(&jobId)
+	if err != nil {
+		return -1, err
+	}
+
+	err = tx.Commit(context.Background())
+	if err != nil {
+		return -1, err
+	}
+
+	return jobId, nil
+}
+
+// CVWorkerPool converts and embeds uploaded CVs in the background.
+type CVWorkerPool struct {
+	pool     *core.Pool
+	embedder core.Embedder
+	chunker  *Chunker
+	skills   *SkillExtractor
+	config   core.CVJobConfig
+	wake     chan struct{}
+}
+
+func NewCVWorkerPool(pool *core.Pool, embedder core.Embedder, chunker *Chunker, skills *SkillExtractor, config core.CVJobConfig) *CVWorkerPool {
+	return &CVWorkerPool{
+		pool:     pool,
+		embedder: embedder,
+		chunker:  chunker,
+		skills:   skills,
+		config:   config,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start runs the workers until ctx is cancelled.
+func (p *CVWorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		go p.work(ctx)
+	}
+}
+
+// Notify wakes an idle worker, so a new job does not wait for the next poll.
+func (p *CVWorkerPool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *CVWorkerPool) work(ctx context.Context) {
+	for {
+		processed, err := p.runNext()
+		if err != nil {
+			log.Printf("CV job worker: %v", err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-time.After(p.config.PollInterval):
+		}
+	}
+}
+
+// runNext processes one job and reports whether there was one.
+func (p *CVWorkerPool) runNext() (bool, error) {
+	job, fileData, err := p.claim()
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim a job: %w", err)
+	}
+
+	// A job whose lease expired was left behind by a crashed worker and
+	// has already used up its attempts.
+	if job.Attempts > p.config.MaxAttempts {
+		return true, p.finish(job.Id, core.CVJobFailed, "Processing did not finish in time.")
+	}
+
+	markdown, err := convertCV(fileData, job.FileName)
+	if err != nil {
+		// Retrying will not make an unreadable file readable.
+		log.Printf("CV job %d: conversion failed: %v", job.Id, err)
+		message := "Failed to convert the CV file."
+		if errors.Is(err, core.ErrUnsupportedCVFormat) {
+			message = "Unsupported file type."
+		}
+		return true, p.finish(job.Id, core.CVJobFailed, message)
+	}
+
+	if err := p.setStatus(job.Id, core.CVJobEmbedding); err != nil {
+		return true, err
+	}
+
+	version := core.CVVersion{
+		UserId:     job.UserId,
+		FileName:   job.FileName,
+		Original:   fileData,
+		Markdown:   markdown,
+		UploadedBy: job.UserId,
+	}
+	if _, err := StoreCVVersion(p.pool, version, p.embedder, p.chunker); err != nil {
+		log.Printf("CV job %d: attempt %d failed: %v", job.Id, job.Attempts, err)
+		if job.Attempts >= p.config.MaxAttempts {
+			return true, p.finish(job.Id, core.CVJobFailed, "Failed to store the CV.")
+		}
+		return true, p.retry(job)
+	}
+
+	// The CV is stored and searchable, missing skills do not fail the job.
+	skills, source := ExtractCVSkills(p.skills, p.chunker, markdown)
+	if err := ReplaceExtractedSkills(p.pool, job.UserId, skills, source); err != nil {
+		log.Printf("CV job %d: failed to store the extracted skills: %v", job.Id, err)
+	}
+
+	return true, p.finish(job.Id, core.CVJobDone, "")
+}
+
+// convertCV converts the uploaded file, turning a panic of a converter on a
+// malformed file into an error, so it fails the job instead of the server.
+func convertCV(fileData []byte, fileName string) (markdown string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("converter panicked: %v", r)
+		}
+	}()
+	return core.ConvertCV(fileData, fileName)
+}
+
+// claim takes the oldest runnable job. SKIP LOCKED lets several workers, also
+// in other replicas, claim jobs at the same time without picking the same one.
+// Jobs of a user whose previous upload is still being processed wait.
+func (p *CVWorkerPool) claim() (core.CVJob, []byte, error) {
+	var job core.CVJob
+	var fileData []byte
+
+	// Start a transaction
+	tx, err := p.pool.Begin(context.Background())
+	if err != nil {
+		return job, nil, err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT id, user_id, file_name, file_data, attempts FROM cv_jobs job
+		WHERE ((status = $1 AND run_after <= now()) OR (status IN ($2, $3) AND locked_until < now()))
+		AND NOT EXISTS (
+			SELECT 1 FROM cv_jobs running
+			WHERE running.user_id = job.user_id AND running.id <> job.id
+			AND running.status IN ($2, $3) AND running.locked_until >= now()
+		)
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`,
+		core.CVJobQueued, core.CVJobConverting, core.CVJobEmbedding).Scan(
+		&job.Id, &job.UserId, &job.FileName, &fileData, &job.Attempts)
+	if err != nil {
+		return job, nil, err
+	}
+
+	job.Attempts++
+	job.Status = core.CVJobConverting
+	_, err = tx.Exec(
+		context.Background(),
+		"UPDATE cv_jobs SET status = $1, attempts = $2, locked_until = now() + make_interval(secs => $3), updated_at = now() WHERE id = $4",
+		job.Status, job.Attempts, p.config.Lease.Seconds(), job.Id)
+	if err != nil {
+		return job, nil, err
+	}
+
+	err = tx.Commit(context.Background())
+	if err != nil {
+		return job, nil, err
+	}
+
+	return job, fileData, nil
+}
+
+func (p *CVWorkerPool) setStatus(jobId int, status string) error {
+	_, err := p.pool.Exec(
+		context.Background(),
+		"UPDATE cv_jobs SET status = $1, updated_at = now() WHERE id = $2",
+		status, jobId)
+	return err
+}
+
+// retry puts the job back in the queue, waiting longer after every attempt.
+func (p *CVWorkerPool) retry(job core.CVJob) error {
+	delay := p.config.RetryDelay * time.Duration(1<<(job.Attempts-1))
+	_, err := p.pool.Exec(
+		context.Background(),
+		"UPDATE cv_jobs SET status = $1, error = $2, run_after = now() + make_interval(secs => $3), locked_until = NULL, updated_at = now() WHERE id = $4",
+		core.CVJobQueued, fmt.Sprintf("Attempt %d failed, retrying.", job.Attempts), delay.Seconds(), job.Id)
+	return err
+}
+
+// finish ends the job, the uploaded file is kept in cv_versions.
+func (p *CVWorkerPool) finish(jobId int, status string, message string) error {
+	_, err := p.pool.Exec(
+		context.Background(),
+		"UPDATE cv_jobs SET status = $1, error = $2, file_data = NULL, locked_until = NULL, updated_at = now() WHERE id = $3",
+		status, message, jobId)
+	return err
+}
@@ -2,10 +2,8 @@ package uploadCV
 
 import (
 	"teamforger/backend/core"
-	"github.com/jackc/pgx/v5"
 	"context"
 
-	"regexp"
 	"sort"
 	"strings"
 	"fmt"
@@ -13,51 +11,64 @@ import (
 	"github.com/pgvector/pgvector-go"
 )
 
-func chunkCV(cv string) []string {
-	sectionHeaders := []string{
-		"PROFESSIONAL SUMMARY",
-		"TECHNICAL EXPERTISE/KNOWLEDGE",
-		"PROJECT HIGHLIGHTS",
-		"TRAININGS",
-		"EDUCATION",
-		"CERTIFICATIONS",
-		"LANGUAGES",
-	}
+// Chunk is a piece of a CV that is embedded on its own.
+type Chunk struct {
+	Section string
+	Text    string
+	// Project is the text of the project or job the chunk was made from, in
+	// sections split into projects.
+	Project string
+}
+
+type Chunker struct {
+	Profiles []CVProfile
+	Limits   ChunkLimits
+}
 
-	upperCV := strings.ToUpper(cv)
-	var sectionsFound []struct {
-		header string
-		start  int
+func NewChunker() (*Chunker, error) {
+	profiles, err := LoadCVProfiles()
+	if err != nil {
+		return nil, err
 	}
-	for _, header := range sectionHeaders {
-		pattern := `(?m)^[^a-zA-Z0-9]*` + regexp.QuoteMeta(header) + `[^a-zA-Z0-9]*$`
-		re := regexp.MustCompile(pattern)
-		loc := re.FindStringIndex(upperCV)
-		if loc != nil {
-			sectionsFound = append(sectionsFound, struct {
-				header string
-				start  int
-			}{header: header, start: loc[0]})
+	return &Chunker{Profiles: profiles, Limits: NewChunkLimits()}, nil
+}
+
+type sectionMatch struct {
+	section CVSection
+	start   int
+}
+
+// Chunk splits a CV into its sections, using the profile that recognises the
+// most of them. Text before the first section becomes a chunk of its own.
+// The sections are then fitted to the chunk limits and every chunk starts
+// with the employee's name and its section.
+func (c *Chunker) Chunk(cv string, employee string) []Chunk {
+	return fitChunks(c.sections(cv), employee, c.Limits)
+}
+
+func (c *Chunker) sections(cv string) []Chunk {
+	var sectionsFound []sectionMatch
+	for _, profile := range c.Profiles {
+		if found := findSections(profile, cv); len(found) > len(sectionsFound) {
+			sectionsFound = found
 		}
 	}
 
 	// Handle case with no sections found
 	if len(sectionsFound) == 0 {
-		return []string{strings.TrimSpace(cv)}
+		if trimmed := strings.TrimSpace(cv); trimmed != "" {
+			return []Chunk{{Text: trimmed}}
+		}
+		return nil
 	}
 
-	// Sort sections by position
-	sort.Slice(sectionsFound, func(i, j int) bool {
-		return sectionsFound[i].start < sectionsFound[j].start
-	})
-
-	var chunks []string
+	var chunks []Chunk
 
 	// Add initial chunk before first section
 	if sectionsFound[0].start > 0 {
 		initialChunk := strings.TrimSpace(cv[:sectionsFound[0].start])
 		if initialChunk != "" {
-			chunks = append(chunks, initialChunk)
+			chunks = append(chunks, Chunk{Text: initialChunk})
 		}
 	}
 
@@ -77,18 +88,18 @@ func chunkCV(cv string) []string {
 			chunk = ""
 		}
 
-		if sec.header == "PROJECT HIGHLIGHTS" {
+		if sec.section.Split == splitProjectsMode {
 			projects := splitProjects(chunk)
 			for _, p := range projects {
 				trimmed := strings.TrimSpace(p)
 				if trimmed != "" {
 					// Add prefix to each project
-					chunks = append(chunks, "Worked in project: "+trimmed)
+					chunks = append(chunks, Chunk{Section: sec.section.Name, Text: sec.section.Prefix + trimmed, Project: trimmed})
 				}
 			}
 		} else {
 			if chunk != "" {
-				chunks = append(chunks, chunk)
+				chunks = append(chunks, Chunk{Section: sec.section.Name, Text: sec.section.Prefix + chunk})
 			}
 		}
 	}
@@ -96,6 +107,39 @@ func chunkCV(cv string) []string {
 	return chunks
 }
 
+// findSections returns where each section of the profile starts, ordered by
+// position. A section starts at the first line matching any of its headers.
+func findSections(profile CVProfile, cv string) []sectionMatch {
+	var sectionsFound []sectionMatch
+	for _, section := range profile.Sections {
+		start := -1
+		for _, pattern := range section.headerPatterns {
+			if loc := pattern.FindStringIndex(cv); loc != nil && (start == -1 || loc[0] < start) {
+				start = loc[0]
+			}
+		}
+		if start != -1 {
+			sectionsFound = append(sectionsFound, sectionMatch{section: section, start: start})
+		}
+	}
+
+	// Sort sections by position
+	sort.SliceStable(sectionsFound, func(i, j int) bool {
+		return sectionsFound[i].start < sectionsFound[j].start
+	})
+
+	// Two sections sharing a header start at the same line, the first one
+	// of the profile wins.
+	var unique []sectionMatch
+	for _, match := range sectionsFound {
+		if len(unique) > 0 && unique[len(unique)-1].start == match.start {
+			continue
+		}
+		unique = append(unique, match)
+	}
+	return unique
+}
+
 // Split project highlights into individual projects
 func splitProjects(chunk string) []string {
 	if chunk == "" {
@@ -186,84 +230,83 @@ func isEnvironmentEnd(currentIndex int, lines []string) bool {
 	return false
 }
 
-type EmbeddingResponse struct {
-	Embedding []float32 `json:"embedding"`
-}
-
-func StoreUserCV(conn *pgx.Conn, user core.User) error {
-	fmt.Println(user.CV)
-	// Store the original CV.
-	// Start a transaction
-	tx, err := conn.Begin(context.Background())
+// StoreCVVersion adds a new version of a CV and makes it the active one,
+// together with its chunks, all at once. Embeddings are computed before
+// anything is written, so a failing embedder leaves the previous version in
+// place and searchable. Chunks and projects of older versions are kept for
+// restoring them.
+func StoreCVVersion(db core.DB, version core.CVVersion, embedder core.Embedder, chunker *Chunker) (int, error) {
+	var employee string
+	err := db.QueryRow(context.Background(), "SELECT COALESCE(name, '') FROM users WHERE id = $1", version.UserId).Scan(&employee)
 	if err != nil {
-		return err
+		return -1, err
 	}
-	// Rollback is safe to call even if the tx is already closed, so if
-	// the tx commits successfully, this is a no-op
-	defer tx.Rollback(context.Background())
-
-	_, err = tx.Exec(context.Background(), "UPDATE users SET cv = $1 WHERE email = $2", user.CV, user.Email)
 
+	taxonomy, err := core.LoadSkillTaxonomy(db)
 	if err != nil {
-		return err
+		return -1, err
 	}
+	projects := chunker.Projects(version.Markdown, taxonomy)
 
-	err = tx.Commit(context.Background())
+	chunks := chunker.Chunk(version.Markdown, employee)
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	embeddings, err := embedder.Embed(context.Background(), texts)
 	if err != nil {
-		return err
+		return -1, fmt.Errorf("failed to embed CV chunks: %w", err)
+	}
+	if len(embeddings) != len(chunks) {
+		return -1, fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunks))
 	}
 
-	// Delete previous chunks for current user.
 	// Start a transaction
-	tx, err = conn.Begin(context.Background())
+	tx, err := db.Begin(context.Background())
 	if err != nil {
-		return err
+		return -1, err
 	}
 	// Rollback is safe to call even if the tx is already closed, so if
 	// the tx commits successfully, this is a no-op
 	defer tx.Rollback(context.Background())
 
-	_, err = tx.Exec(context.Background(), "DELETE FROM cv_chunks WHERE user_id = $1", user.Id)
-
+	_, err = tx.Exec(context.Background(), "UPDATE cv_versions SET is_active = FALSE WHERE user_id = $1 AND is_active", version.UserId)
 	if err != nil {
-		return err
+		return -1, err
 	}
 
-	err = tx.Commit(context.Background())
+	var versionId int
+	err = tx.QueryRow(
+		context.Background(),
+		"INSERT INTO cv_versions (user_id, file_name, original, markdown, uploaded_by, is_active) VALUES ($1, $2, $3, $4, $5, TRUE) RETURNING id",
+		version.UserId, version.FileName, version.Original, version.Markdown, version.UploadedBy).Scan(&versionId)
 	if err != nil {
-		return err
+		return -1, err
 	}
 
-	// Insert the chunked CV.
-	chunks := chunkCV(user.CV)
-	for i := 0; i < len(chunks); i++ {
-		embeddingFA, err := core.GetEmbedding(chunks[i])
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			return err
-		}
-		embedding := pgvector.NewVector(embeddingFA)
+	_, err = tx.Exec(context.Background(), "UPDATE users SET cv = $1 WHERE id = $2", version.Markdown, version.UserId)
+	if err != nil {
+		return -1, err
+	}
 
-		// Start a transaction
-		tx, err := conn.Begin(context.Background())
+	for i, chunk := range chunks {
+		_, err = tx.Exec(
+			context.Background(),
+			"INSERT INTO cv_chunks (user_id, version_id, section, chunk, embedding) VALUES ($1, $2, $3, $4, $5)",
+			version.UserId, versionId, chunk.Section, chunk.Text, pgvector.NewVector(embeddings[i]))
 		if err != nil {
-			return err
+			return -1, err
 		}
-		// Rollback is safe to call even if the tx is already closed, so if
-		// the tx commits successfully, this is a no-op
-		defer tx.Rollback(context.Background())
-
-		_, err = tx.Exec(context.Background(), "INSERT INTO cv_chunks (user_id, chunk, embedding) VALUES ($1, $2, $3)", user.Id, chunks[i], embedding)
+	}
 
-		if err != nil {
-			return err
-		}
+	if err := insertProjects(tx, version.UserId, versionId, projects); err != nil {
+		return -1, err
+	}
 
-		err = tx.Commit(context.Background())
-		if err != nil {
-			return err
-		}
+	err = tx.Commit(context.Background())
+	if err != nil {
+		return -1, err
 	}
-	return nil
-}
 
+	return versionId, nil
+}
@@ -0,0 +1,87 @@
+package uploadCV
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// The default section profiles. CV_PROFILES_FILE points to a file in the same
+// format to replace them.
+//
+//go:embed cv_profiles.json
+var defaultCVProfiles []byte
+
+// CVProfile describes the sections of one CV template. The profile matching
+// the most section headers of a CV is used to chunk it.
+type CVProfile struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Sections    []CVSection `json:"sections"`
+}
+
+type CVSection struct {
+	// Name is stored with every chunk of the section.
+	Name string `json:"name"`
+	// Headers are the headings starting the section, matched case-insensitively
+	// against whole lines, ignoring surrounding punctuation and markdown.
+	Headers []string `json:"headers"`
+	// Prefix is put in front of every chunk of the section.
+	Prefix string `json:"prefix"`
+	// Split "projects" makes one chunk per project or job instead of one for
+	// the whole section.
+	Split string `json:"split"`
+
+	headerPatterns []*regexp.Regexp
+}
+
+const splitProjectsMode = "projects"
+
+// LoadCVProfiles reads the profiles from CV_PROFILES_FILE, or the built in
+// ones when it is not set.
+func LoadCVProfiles() ([]CVProfile, error) {
+	data := defaultCVProfiles
+	if path := os.Getenv("CV_PROFILES_FILE"); path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CV_PROFILES_FILE: %w", err)
+		}
+	}
+	return ParseCVProfiles(data)
+}
+
+func ParseCVProfiles(data []byte) ([]CVProfile, error) {
+	var config struct {
+		Profiles []CVProfile `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid CV profiles: %w", err)
+	}
+	if len(config.Profiles) == 0 {
+		return nil, fmt.Errorf("invalid CV profiles: no profile defined")
+	}
+
+	for i := range config.Profiles {
+		profile := &config.Profiles[i]
+		if profile.Name == "" {
+			return nil, fmt.Errorf("invalid CV profiles: profile %d has no name", i+1)
+		}
+		for j := range profile.Sections {
+			section := &profile.Sections[j]
+			if section.Name == "" || len(section.Headers) == 0 {
+				return nil, fmt.Errorf("invalid CV profile %q: section %d needs a name and headers", profile.Name, j+1)
+			}
+			if section.Split != "" && section.Split != splitProjectsMode {
+				return nil, fmt.Errorf("invalid CV profile %q: unknown split %q in section %q", profile.Name, section.Split, section.Name)
+			}
+			for _, header := range section.Headers {
+				pattern := `(?mi)^[^\p{L}\p{N}\n]*` + regexp.QuoteMeta(header) + `[^\p{L}\p{N}\n]*$`
+				section.headerPatterns = append(section.headerPatterns, regexp.MustCompile(pattern))
+			}
+		}
+	}
+	return config.Profiles, nil
+}
@@ -0,0 +1,375 @@
+package uploadCV
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"teamforger/backend/core"
+)
+
+// The field labels of a project, lower case, in the languages of the CV
+// profiles.
+var projectLabels = map[string]string{
+	"project":          "name",
+	"project name":     "name",
+	"projekt":          "name",
+	"проект":           "name",
+	"client":           "client",
+	"customer":         "client",
+	"company":          "client",
+	"employer":         "client",
+	"kunde":            "client",
+	"auftraggeber":     "client",
+	"arbeitgeber":      "client",
+	"firma":            "client",
+	"клиент":           "client",
+	"компания":         "client",
+	"фирма":            "client",
+	"работодател":      "client",
+	"role":             "role",
+	"position":         "role",
+	"rolle":            "role",
+	"funktion":         "role",
+	"роля":             "role",
+	"позиция":          "role",
+	"длъжност":         "role",
+	"team size":        "team",
+	"team":             "team",
+	"teamgröße":        "team",
+	"teamgroesse":      "team",
+	"размер на екипа":  "team",
+	"екип":             "team",
+	"environment":      "technologies",
+	"technologies":     "technologies",
+	"tech stack":       "technologies",
+	"technology stack": "technologies",
+	"tools":            "technologies",
+	"umgebung":         "technologies",
+	"technologien":     "technologies",
+	"среда":            "technologies",
+	"технологии":       "technologies",
+	"period":           "period",
+	"duration":         "period",
+	"dates":            "period",
+	"date":             "period",
+	"zeitraum":         "period",
+	"dauer":            "period",
+	"период":           "period",
+	"времетраене":      "period",
+	"продължителност":  "period",
+}
+
+var (
+	// "Role: Backend developer", "**Client:** ACME", "- Environment: Go, SQL"
+	projectLabel = regexp.MustCompile(`^[\s\-*•>]*\**\s*(\p{L}[\p{L} ]{0,30}?)\s*\**\s*:\s*\**\s*(.*)$`)
+	// "03/2021", "03.2021", "2021-03", "March 2021", "Mär. 2021", "2021"
+	projectDate = regexp.MustCompile(`(?i)\b(?:(jan|feb|mar|mär|apr|may|mai|jun|jul|aug|sep|oct|okt|nov|dec|dez)\p{L}*\.?\s+((?:19|20)\d{2})|(\d{1,2})[./]((?:19|20)\d{2})|((?:19|20)\d{2})-(\d{1,2})|((?:19|20)\d{2}))\b`)
+	// The end of a project that is still running.
+	projectOngoing  = regexp.MustCompile(`(?i)\b(?:present|now|today|current|ongoing|heute|aktuell|laufend)\b|сега|момента|настояще`)
+	projectBrackets = regexp.MustCompile(`\(([^)]*)\)`)
+	firstNumber     = regexp.MustCompile(`\d+`)
+)
+
+var monthNumbers = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "mär": 3, "apr": 4, "may": 5, "mai": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "okt": 10, "nov": 11, "dec": 12, "dez": 12,
+}
+
+const maxProjectTechnologies = 50
+
+// Projects parses the projects and jobs of the project sections of a CV.
+// Technologies are stored under their canonical skill names.
+func (c *Chunker) Projects(cv string, taxonomy *core.SkillTaxonomy) []core.EmployeeProject {
+	var projects []core.EmployeeProject
+	for _, chunk := range c.sections(cv) {
+		if chunk.Project == "" {
+			continue
+		}
+		if project, ok := ParseProject(chunk.Project, taxonomy); ok {
+			project.Section = chunk.Section
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// ParseProject reads a project as found by splitProjects: a title line with
+// the name and usually the client and dates in parentheses, followed by
+// labelled lines like "Role:" and ending with "Environment:".
+func ParseProject(text string, taxonomy *core.SkillTaxonomy) (core.EmployeeProject, bool) {
+	var project core.EmployeeProject
+	var datesText string
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return project, false
+	}
+
+	title := cleanProjectLine(lines[0])
+	project.Name, project.Client, datesText = parseProjectTitle(title)
+	titleDates := datesText
+
+	for _, line := range lines[1:] {
+		match := projectLabel.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		field, ok := projectLabels[strings.ToLower(strings.TrimSpace(match[1]))]
+		value := cleanProjectLine(match[2])
+		if !ok || value == "" {
+			continue
+		}
+		switch field {
+		case "name":
+			project.Name = value
+		case "client":
+			if project.Client == "" {
+				project.Client = value
+			}
+		case "role":
+			project.Role = value
+		case "team":
+			if number := firstNumber.FindString(value); number != "" {
+				if size, err := strconv.Atoi(number); err == nil && size > 0 {
+					project.TeamSize = &size
+				}
+			}
+		case "technologies":
+			project.Technologies = append(project.Technologies, projectTechnologies(value, taxonomy)...)
+		case "period":
+			datesText = value
+		}
+	}
+
+	// "Senior developer at ACME" names the role and the employer.
+	if project.Role == "" && project.Client == "" {
+		for _, separator := range []string{" at ", " bei ", " @ ", " в "} {
+			if idx := strings.Index(project.Name, separator); idx > 0 {
+				project.Role = strings.TrimSpace(project.Name[:idx])
+				project.Client = strings.TrimSpace(project.Name[idx+len(separator):])
+				break
+			}
+		}
+	}
+
+	project.StartDate, project.EndDate, project.IsCurrent = parseProjectDates(datesText)
+	if project.StartDate == nil && datesText != titleDates {
+		project.StartDate, project.EndDate, project.IsCurrent = parseProjectDates(titleDates)
+	}
+	project.Technologies = uniqueStrings(project.Technologies)
+	if len(project.Technologies) > maxProjectTechnologies {
+		project.Technologies = project.Technologies[:maxProjectTechnologies]
+	}
+	return project, project.Name != ""
+}
+
+func cleanProjectLine(line string) string {
+	line = strings.ReplaceAll(line, "**", "")
+	line = strings.ReplaceAll(line, "__", "")
+	return strings.Trim(line, " #*_>-–—•|:")
+}
+
+// parseProjectTitle takes the name, the client and the dates from a title
+// line. Client and dates are usually in parentheses, dates may also follow
+// the name without them.
+func parseProjectTitle(title string) (string, string, string) {
+	client, dates := "", ""
+	for _, group := range projectBrackets.FindAllStringSubmatch(title, -1) {
+		for _, part := range strings.FieldsFunc(group[1], func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if projectDate.MatchString(part) || projectOngoing.MatchString(part) {
+				if dates == "" {
+					dates = part
+				}
+				continue
+			}
+			if match := projectLabel.FindStringSubmatch(part); match != nil {
+				if projectLabels[strings.ToLower(strings.TrimSpace(match[1]))] != "client" {
+					continue
+				}
+				part = strings.TrimSpace(match[2])
+			}
+			if client == "" {
+				client = part
+			}
+		}
+	}
+
+	name := projectBrackets.ReplaceAllString(title, "")
+	if dates == "" {
+		if loc := projectDate.FindStringIndex(name); loc != nil {
+			dates = name[loc[0]:]
+			name = name[:loc[0]]
+		}
+	}
+	name = strings.Trim(name, " -–—|,:")
+	if name == "" {
+		name = title
+	}
+	return name, client, dates
+}
+
+// parseProjectDates finds the first date as the start and the second one, or
+// a word like "present", as the end. A year alone starts in January and ends
+// in December, a single date is both start and end.
+func parseProjectDates(text string) (*time.Time, *time.Time, bool) {
+	matches := projectDate.FindAllStringSubmatchIndex(text, -1)
+	var dates []time.Time
+	var yearOnly []bool
+	var ends []int
+	for _, match := range matches {
+		group := func(i int) string {
+			if match[2*i] < 0 {
+				return ""
+			}
+			return text[match[2*i]:match[2*i+1]]
+		}
+
+		year, month := 0, 0
+		switch {
+		case group(1) != "":
+			year, _ = strconv.Atoi(group(2))
+			month = monthNumbers[strings.ToLower(group(1))]
+		case group(3) != "":
+			year, _ = strconv.Atoi(group(4))
+			month, _ = strconv.Atoi(group(3))
+		case group(5) != "":
+			year, _ = strconv.Atoi(group(5))
+			month, _ = strconv.Atoi(group(6))
+		default:
+			year, _ = strconv.Atoi(group(7))
+		}
+		if year < 1950 || year > time.Now().Year()+1 || month < 0 || month > 12 {
+			continue
+		}
+		dates = append(dates, time.Date(year, time.Month(max(month, 1)), 1, 0, 0, 0, 0, time.UTC))
+		yearOnly = append(yearOnly, month == 0)
+		ends = append(ends, match[1])
+		if len(dates) == 2 {
+			break
+		}
+	}
+	if len(dates) == 0 {
+		return nil, nil, false
+	}
+
+	start := dates[0]
+	if loc := projectOngoing.FindStringIndex(text); loc != nil && loc[0] >= ends[0] && (len(dates) == 1 || loc[0] < ends[1]) {
+		return &start, nil, true
+	}
+
+	end := dates[len(dates)-1]
+	if yearOnly[len(dates)-1] {
+		end = time.Date(end.Year(), time.December, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if end.Before(start) {
+		return &start, nil, false
+	}
+	return &start, &end, false
+}
+
+func projectTechnologies(value string, taxonomy *core.SkillTaxonomy) []string {
+	var technologies []string
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' || r == '•' }) {
+		name := core.NormalizeSkillName(part)
+		if taxonomy != nil {
+			name, _ = taxonomy.Canonical(name)
+		}
+		if name != "" && len([]rune(name)) <= 60 {
+			technologies = append(technologies, name)
+		}
+	}
+	return technologies
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if key := strings.ToLower(value); !seen[key] {
+			seen[key] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// insertProjects stores the projects parsed from a CV version.
+func insertProjects(db core.DB, userId int, versionId int, projects []core.EmployeeProject) error {
+	for _, project := range projects {
+		technologies := project.Technologies
+		if technologies == nil {
+			technologies = []string{}
+		}
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO employee_projects (user_id, version_id, section, name, client, role, start_date, end_date, is_current, team_size, technologies)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			userId, versionId, project.Section, project.Name, project.Client, project.Role,
+			project.StartDate, project.EndDate, project.IsCurrent, project.TeamSize, technologies)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractMissingProjects parses the projects of active CV versions stored
+// before projects were extracted, and returns how many it found.
+func ExtractMissingProjects(db core.DB, chunker *Chunker) (int, error) {
+	taxonomy, err := core.LoadSkillTaxonomy(db)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, user_id, markdown FROM cv_versions
+		WHERE is_active AND NOT EXISTS (SELECT 1 FROM employee_projects WHERE employee_projects.version_id = cv_versions.id)`)
+	if err != nil {
+		return 0, err
+	}
+	var versions []core.CVVersion
+	for rows.Next() {
+		var version core.CVVersion
+		if err := rows.Scan(&version.Id, &version.UserId, &version.Markdown); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		versions = append(versions, version)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	found := 0
+	for _, version := range versions {
+		projects := chunker.Projects(version.Markdown, taxonomy)
+
+		// A version is done all at once, or picked up again on the next run.
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			return found, err
+		}
+		if err := insertProjects(tx, version.UserId, version.Id, projects); err != nil {
+			tx.Rollback(context.Background())
+			return found, err
+		}
+		if err := tx.Commit(context.Background()); err != nil {
+			return found, err
+		}
+		found += len(projects)
+	}
+	return found, nil
+}
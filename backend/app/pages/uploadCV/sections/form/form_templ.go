@@ -9,6 +9,7 @@ import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
 import (
+	"strings"
 	"teamforger/backend/core"
 )
 
@@ -40,13 +41,26 @@ func Form(user core.User) templ.Component {
 		var templ_7745c5c3_Var2 string
 		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(user.CSRFToken)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/form/form.templ`, Line: 12, Col: 64}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/form/form.templ`, Line: 13, Col: 64}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\"><!-- File upload input --><div class=\"mb-3\"><label for=\"file\" class=\"form-label\">Input CV in docx format here</label> <input class=\"form-control\" type=\"file\" id=\"file\" name=\"file\" accept=\".docx\" required></div><!-- Submit Button --><button type=\"submit\" class=\"btn btn-primary w-100 py-2 mb-3\">Upload CV<i class=\"bi bi-arrow-right-short\"></i></button></form></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\"><!-- File upload input --><div class=\"mb-3\"><label for=\"file\" class=\"form-label\">Input your CV here (DOCX, PDF, ODT, HTML, Markdown or plain text)</label> <input class=\"form-control\" type=\"file\" id=\"file\" name=\"file\" accept=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var3 string
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(strings.Join(core.CVFileExtensions(), ","))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/form/form.templ`, Line: 18, Col: 117}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\" required></div><!-- Submit Button --><button type=\"submit\" class=\"btn btn-primary w-100 py-2 mb-3\">Upload CV<i class=\"bi bi-arrow-right-short\"></i></button></form></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
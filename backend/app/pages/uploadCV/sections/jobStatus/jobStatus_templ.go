@@ -0,0 +1,96 @@
+// Code generated by templ - DO NOT EDIT.
+
+// templ: version: v0.3.887
+package jobStatus
+
+//lint:file-ignore SA4006 This context is only used if a nested component is present.
+
+import "github.com/a-h/templ"
+import templruntime "github.com/a-h/templ/runtime"
+
+import (
+	"teamforger/backend/core"
+)
+
+func JobStatus(job core.CVJob) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var1 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var1 == nil {
+			templ_7745c5c3_Var1 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"col-md-8 col-lg-6 mb-4\"><div class=\"card p-4\" id=\"cv-job\" data-job-id=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(job.Id)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/jobStatus/jobStatus.templ`, Line: 9, Col: 55}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" data-status=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var3 string
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(job.Status)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/jobStatus/jobStatus.templ`, Line: 9, Col: 82}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\"><h5 class=\"mb-1\">Processing ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var4 string
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(job.FileName)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/jobStatus/jobStatus.templ`, Line: 10, Col: 44}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</h5><p class=\"text-muted small mb-3\" id=\"cv-job-message\"></p><div class=\"progress\" role=\"progressbar\" aria-label=\"CV processing progress\"><div class=\"progress-bar progress-bar-striped progress-bar-animated\" id=\"cv-job-progress\" style=\"width: 0%\"></div></div><p class=\"text-danger small mt-3 mb-0 d-none\" id=\"cv-job-error\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var5 string
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(job.Error)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pages/uploadCV/sections/jobStatus/jobStatus.templ`, Line: 15, Col: 77}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</p></div></div><script>\n\t(function() {\n\t\tconst card = document.getElementById('cv-job');\n\t\tconst message = document.getElementById('cv-job-message');\n\t\tconst progress = document.getElementById('cv-job-progress');\n\t\tconst errorText = document.getElementById('cv-job-error');\n\n\t\tconst steps = {\n\t\t\tqueued: { percent: 10, text: \"Waiting for a free worker...\" },\n\t\t\tconverting: { percent: 40, text: \"Converting the file...\" },\n\t\t\tembedding: { percent: 75, text: \"Indexing the CV for search...\" },\n\t\t\tdone: { percent: 100, text: \"Your CV has been uploaded and converted.\" },\n\t\t\tfailed: { percent: 100, text: \"Processing your CV failed.\" }\n\t\t};\n\n\t\tfunction render(job) {\n\t\t\tconst step = steps[job.status] || steps.queued;\n\t\t\tmessage.textContent = step.text;\n\t\t\tprogress.style.width = step.percent + '%';\n\n\t\t\t// A queued job with an error is waiting for a retry.\n\t\t\tif (job.error) {\n\t\t\t\terrorText.textContent = job.error;\n\t\t\t\terrorText.classList.remove('d-none');\n\t\t\t} else {\n\t\t\t\terrorText.classList.add('d-none');\n\t\t\t}\n\n\t\t\tif (job.status === 'done' || job.status === 'failed') {\n\t\t\t\tprogress.classList.remove('progress-bar-animated', 'progress-bar-striped');\n\t\t\t\tprogress.classList.add(job.status === 'done' ? 'bg-success' : 'bg-danger');\n\t\t\t\treturn true;\n\t\t\t}\n\t\t\treturn false;\n\t\t}\n\n\t\tfunction poll() {\n\t\t\tfetch('/uploadCVStatus?job=' + card.dataset.jobId, { credentials: 'same-origin' })\n\t\t\t\t.then(response => {\n\t\t\t\t\tif (!response.ok) {\n\t\t\t\t\t\tthrow new Error('status ' + response.status);\n\t\t\t\t\t}\n\t\t\t\t\treturn response.json();\n\t\t\t\t})\n\t\t\t\t.then(job => {\n\t\t\t\t\tif (!render(job)) {\n\t\t\t\t\t\tsetTimeout(poll, 2000);\n\t\t\t\t\t}\n\t\t\t\t})\n\t\t\t\t.catch(() => setTimeout(poll, 5000));\n\t\t}\n\n\t\tconst finished = render({ status: card.dataset.status, error: errorText.textContent });\n\t\tif (!finished) {\n\t\t\tsetTimeout(poll, 1000);\n\t\t}\n\t})();\n</script>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+var _ = templruntime.GeneratedTemplate
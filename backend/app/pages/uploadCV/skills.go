@@ -0,0 +1,286 @@
+package uploadCV
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"teamforger/backend/core"
+)
+
+// ExtractedSkill is a skill found in a CV, the numbers are nil when the CV
+// does not tell.
+type ExtractedSkill struct {
+	Name            string   `json:"name"`
+	YearsExperience *float64 `json:"years_of_experience"`
+	LastUsedYear    *int     `json:"last_used_year"`
+}
+
+const skillSchema = `{
+	"type": "object",
+	"properties": {
+		"skills": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"years_of_experience": {"type": ["number", "null"]},
+					"last_used_year": {"type": ["integer", "null"]}
+				},
+				"required": ["name", "years_of_experience", "last_used_year"]
+			}
+		}
+	},
+	"required": ["skills"]
+}`
+
+const skillExtractionPrompt = `You extract the technical skills of an employee from their CV.
+List every programming language, framework, library, database, cloud service, tool and methodology the employee has worked with.
+Use the common name of each skill, e.g. "Kubernetes" instead of "K8s" and "PostgreSQL" instead of "Postgres".
+Set years_of_experience only when the CV states it or the project dates show it, and last_used_year to the last year of a project using the skill. Use null otherwise.
+Reply only with JSON.`
+
+// The LLM sees at most this much of the CV, the rest would not fit its context.
+const maxSkillExtractionChars = 12000
+
+const maxExtractedSkills = 80
+
+// SkillExtractor finds the skills in a CV with the chat model, falling back
+// to rules over the skills section when the model fails.
+type SkillExtractor struct {
+	LLM     core.ChatProvider
+	Timeout time.Duration
+}
+
+func NewSkillExtractor(llm core.ChatProvider) *SkillExtractor {
+	return &SkillExtractor{
+		LLM:     llm,
+		Timeout: core.EnvDuration("SKILL_EXTRACTION_TIMEOUT", 2*time.Minute),
+	}
+}
+
+// ExtractCVSkills returns the skills of a CV given as markdown and where
+// they come from.
+func ExtractCVSkills(extractor *SkillExtractor, chunker *Chunker, markdown string) ([]ExtractedSkill, string) {
+	return extractor.Extract(markdown, chunker.sections(markdown))
+}
+
+// Extract returns the skills of the CV and where they come from.
+func (e *SkillExtractor) Extract(cv string, sections []Chunk) ([]ExtractedSkill, string) {
+	if e.LLM != nil {
+		skills, err := e.extractWithLLM(cv)
+		if err == nil && len(skills) > 0 {
+			return skills, core.SkillSourceLLM
+		}
+		if err != nil {
+			log.Printf("LLM skill extraction failed, using rules: %v", err)
+		}
+	}
+	return extractSkillsWithRules(sections), core.SkillSourceRules
+}
+
+func (e *SkillExtractor) extractWithLLM(cv string) ([]ExtractedSkill, error) {
+	if runes := []rune(cv); len(runes) > maxSkillExtractionChars {
+		cv = string(runes[:maxSkillExtractionChars])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+
+	reply, err := e.LLM.StreamChat(ctx, core.ChatRequest{
+		Messages: []core.ChatMessage{
+			{Role: "system", Content: skillExtractionPrompt},
+			{Role: "user", Content: cv},
+		},
+		Format: json.RawMessage(skillSchema),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Skills []ExtractedSkill `json:"skills"`
+	}
+	if err := json.Unmarshal([]byte(core.JSONObject(reply)), &result); err != nil {
+		return nil, fmt.Errorf("invalid skill JSON: %w", err)
+	}
+	return cleanSkills(result.Skills), nil
+}
+
+// Sections whose lines are lists of skills.
+var skillSections = map[string]bool{
+	"technical expertise": true,
+	"skills":              true,
+}
+
+var (
+	// "Go (5 years)", "Java - 3+ yrs", "SQL, 4 Jahre", "Python 2 години"
+	skillYears = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\+?\s*(?:years?|yrs?|jahre?n?|години|год\.?)`)
+	// "(2015 - 2021)", "2019-present", a single year is the last one used
+	skillYearRange = regexp.MustCompile(`(?i)\b((?:19|20)\d{2})\s*[-–]\s*((?:19|20)\d{2}\b|present\b|now\b|today\b|heute\b|сега)`)
+	skillLastYear  = regexp.MustCompile(`\b((?:19|20)\d{2})\b`)
+	skillBrackets  = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]`)
+)
+
+// extractSkillsWithRules reads the skills sections line by line. A line like
+// "Languages: Go (5 years), Java" lists skills after the category name.
+func extractSkillsWithRules(sections []Chunk) []ExtractedSkill {
+	var skills []ExtractedSkill
+	for _, section := range sections {
+		if !skillSections[strings.ToLower(section.Section)] {
+			continue
+		}
+		for _, line := range strings.Split(section.Text, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(line, " -*•#>"))
+			if idx := strings.Index(line, ":"); idx != -1 && idx < 40 {
+				line = line[idx+1:]
+			}
+			skills = append(skills, skillsFromLine(line)...)
+		}
+	}
+	return cleanSkills(skills)
+}
+
+func skillsFromLine(line string) []ExtractedSkill {
+	var skills []ExtractedSkill
+	// Separators inside brackets belong to the details, not the list.
+	var parts []string
+	depth, last := 0, 0
+	for i, r := range line {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth = max(depth-1, 0)
+		case ',', ';', '|', '•', '●', '▪', '·':
+			if depth == 0 {
+				parts = append(parts, line[last:i])
+				last = i + len(string(r))
+			}
+		}
+	}
+	parts = append(parts, line[last:])
+
+	for _, part := range parts {
+		skill := ExtractedSkill{Name: skillBrackets.ReplaceAllString(part, "")}
+		if match := skillYears.FindStringSubmatch(part); match != nil {
+			if years, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64); err == nil {
+				skill.YearsExperience = &years
+			}
+			skill.Name = strings.Replace(skill.Name, match[0], "", 1)
+		}
+		if match := skillYearRange.FindStringSubmatch(part); match != nil {
+			year := time.Now().Year()
+			if end, err := strconv.Atoi(match[2]); err == nil {
+				year = end
+			}
+			skill.LastUsedYear = &year
+		} else if matches := skillLastYear.FindAllString(part, -1); len(matches) > 0 {
+			year, _ := strconv.Atoi(matches[len(matches)-1])
+			skill.LastUsedYear = &year
+		}
+		skill.Name = skillLastYear.ReplaceAllString(skill.Name, "")
+		skills = append(skills, skill)
+	}
+	return skills
+}
+
+// cleanSkills normalizes names, drops what cannot be a skill name and keeps
+// the first of duplicates.
+func cleanSkills(skills []ExtractedSkill) []ExtractedSkill {
+	currentYear := time.Now().Year()
+	seen := map[string]bool{}
+	var cleaned []ExtractedSkill
+	for _, skill := range skills {
+		skill.Name = core.NormalizeSkillName(skill.Name)
+		key := strings.ToLower(skill.Name)
+		if skill.Name == "" || seen[key] || len([]rune(skill.Name)) > 40 || len(strings.Fields(skill.Name)) > 4 {
+			continue
+		}
+		if skill.YearsExperience != nil && (*skill.YearsExperience <= 0 || *skill.YearsExperience > 50) {
+			skill.YearsExperience = nil
+		}
+		if skill.LastUsedYear != nil && (*skill.LastUsedYear < 1970 || *skill.LastUsedYear > currentYear+1) {
+			skill.LastUsedYear = nil
+		}
+		seen[key] = true
+		cleaned = append(cleaned, skill)
+		if len(cleaned) == maxExtractedSkills {
+			break
+		}
+	}
+	return cleaned
+}
+
+// ReplaceExtractedSkills swaps the skills extracted from the previous CV for
+// the new ones. Skills the employee entered or edited stay as they are.
+func ReplaceExtractedSkills(db core.DB, userId int, skills []ExtractedSkill, source string) error {
+	if source == core.SkillSourceManual {
+		return errors.New("extracted skills cannot have the manual source")
+	}
+
+	// Start a transaction
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	// Rollback is safe to call even if the tx is already closed, so if
+	// the tx commits successfully, this is a no-op
+	defer tx.Rollback(context.Background())
+
+	taxonomy, err := core.LoadSkillTaxonomy(tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(context.Background(), "DELETE FROM employee_skills WHERE user_id = $1 AND source <> $2", userId, core.SkillSourceManual)
+	if err != nil {
+		return err
+	}
+
+	// "k8s" and "Kubernetes" in the same CV are one skill.
+	for _, skill := range canonicalSkills(skills, taxonomy) {
+		name, skillId := taxonomy.Canonical(skill.Name)
+		_, err = tx.Exec(
+			context.Background(),
+			`INSERT INTO employee_skills (user_id, skill, skill_id, years_experience, last_used_year, source) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, lower(skill)) DO NOTHING`,
+			userId, name, skillId, skill.YearsExperience, skill.LastUsedYear, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// canonicalSkills renames the skills to their canonical names and merges the
+// ones that turn out to be the same, keeping the highest numbers.
+func canonicalSkills(skills []ExtractedSkill, taxonomy *core.SkillTaxonomy) []ExtractedSkill {
+	positions := map[string]int{}
+	var merged []ExtractedSkill
+	for _, skill := range skills {
+		skill.Name, _ = taxonomy.Canonical(skill.Name)
+		key := strings.ToLower(skill.Name)
+		i, ok := positions[key]
+		if !ok {
+			positions[key] = len(merged)
+			merged = append(merged, skill)
+			continue
+		}
+		if skill.YearsExperience != nil && (merged[i].YearsExperience == nil || *skill.YearsExperience > *merged[i].YearsExperience) {
+			merged[i].YearsExperience = skill.YearsExperience
+		}
+		if skill.LastUsedYear != nil && (merged[i].LastUsedYear == nil || *skill.LastUsedYear > *merged[i].LastUsedYear) {
+			merged[i].LastUsedYear = skill.LastUsedYear
+		}
+	}
+	return merged
+}
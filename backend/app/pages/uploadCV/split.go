@@ -0,0 +1,207 @@
+package uploadCV
+
+import (
+	"regexp"
+	"strings"
+
+	"teamforger/backend/core"
+)
+
+// ChunkLimits bound the size of the chunks in tokens as estimated by
+// core.CountTokens, including the header naming the employee and section.
+type ChunkLimits struct {
+	// MaxTokens keeps chunks within the embedding model's context, which
+	// would otherwise silently cut them off.
+	MaxTokens int
+	// OverlapTokens of the end of a split chunk are repeated at the start of
+	// the next one, so text around the cut is found from both sides.
+	OverlapTokens int
+	// Chunks smaller than MinTokens are merged into a neighbour of the same
+	// section.
+	MinTokens int
+}
+
+func NewChunkLimits() ChunkLimits {
+	limits := ChunkLimits{
+		MaxTokens:     max(core.EnvInt("CHUNK_MAX_TOKENS", 400), 50),
+		OverlapTokens: max(core.EnvInt("CHUNK_OVERLAP_TOKENS", 40), 0),
+		MinTokens:     max(core.EnvInt("CHUNK_MIN_TOKENS", 40), 0),
+	}
+	limits.OverlapTokens = min(limits.OverlapTokens, limits.MaxTokens/2)
+	limits.MinTokens = min(limits.MinTokens, limits.MaxTokens/2)
+	return limits
+}
+
+// chunkHeader names the employee and section, so every chunk can be matched
+// and understood on its own.
+func chunkHeader(employee string, section string) string {
+	header := "Employee: " + employee + "\n"
+	if section != "" {
+		header += "Section: " + section + "\n"
+	}
+	return header + "\n"
+}
+
+// fitChunks splits chunks exceeding the budget on paragraph, line, sentence
+// and finally word boundaries, merges fragments that are too small and puts
+// the header on every chunk.
+func fitChunks(chunks []Chunk, employee string, limits ChunkLimits) []Chunk {
+	var fitted []Chunk
+	for _, chunk := range chunks {
+		budget := max(limits.MaxTokens-core.CountTokens(chunkHeader(employee, chunk.Section)), 1)
+		for _, piece := range splitText(chunk.Text, budget, limits.OverlapTokens) {
+			fitted = append(fitted, Chunk{Section: chunk.Section, Text: piece})
+		}
+	}
+
+	fitted = mergeSmallChunks(fitted, employee, limits)
+
+	for i := range fitted {
+		fitted[i].Text = chunkHeader(employee, fitted[i].Section) + fitted[i].Text
+	}
+	return fitted
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?;]\s+`)
+
+// splitText packs the units of text into pieces of at most budget tokens.
+func splitText(text string, budget int, overlap int) []string {
+	if core.CountTokens(text) <= budget {
+		return []string{text}
+	}
+
+	var pieces []string
+	var current []string
+	currentTokens := 0
+	for _, unit := range textUnits(text, budget) {
+		unitTokens := core.CountTokens(unit)
+		if currentTokens+unitTokens > budget && len(current) > 0 {
+			pieces = append(pieces, strings.Join(current, "\n"))
+			current = overlapUnits(current, overlap, budget-unitTokens)
+			currentTokens = core.CountTokens(strings.Join(current, "\n"))
+		}
+		current = append(current, unit)
+		currentTokens += unitTokens
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, "\n"))
+	}
+	return pieces
+}
+
+// textUnits breaks text into paragraphs, lines, sentences or words, going
+// finer only where a unit alone exceeds the budget.
+func textUnits(text string, budget int) []string {
+	var units []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		for _, unit := range splitUnit(strings.TrimSpace(paragraph), budget, 0) {
+			if unit != "" {
+				units = append(units, unit)
+			}
+		}
+	}
+	return units
+}
+
+func splitUnit(unit string, budget int, level int) []string {
+	if unit == "" || core.CountTokens(unit) <= budget {
+		return []string{unit}
+	}
+
+	var parts []string
+	switch level {
+	case 0:
+		parts = strings.Split(unit, "\n")
+	case 1:
+		// Keep the punctuation with its sentence.
+		last := 0
+		for _, loc := range sentenceEnd.FindAllStringIndex(unit, -1) {
+			parts = append(parts, unit[last:loc[0]+1])
+			last = loc[1]
+		}
+		parts = append(parts, unit[last:])
+	default:
+		return splitWords(unit, budget)
+	}
+
+	var units []string
+	for _, part := range parts {
+		units = append(units, splitUnit(strings.TrimSpace(part), budget, level+1)...)
+	}
+	return units
+}
+
+func splitWords(text string, budget int) []string {
+	var pieces []string
+	var current []string
+	currentTokens := 0
+	for _, word := range strings.Fields(text) {
+		wordTokens := core.CountTokens(word)
+		if currentTokens+wordTokens > budget && len(current) > 0 {
+			pieces = append(pieces, strings.Join(current, " "))
+			current, currentTokens = nil, 0
+		}
+		current = append(current, word)
+		currentTokens += wordTokens
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, " "))
+	}
+	return pieces
+}
+
+// overlapUnits returns the trailing units of a finished piece that fit in the
+// overlap, and in the room left next to the unit starting the next piece.
+func overlapUnits(units []string, overlap int, room int) []string {
+	limit := min(overlap, room)
+	var tail []string
+	tokens := 0
+	for i := len(units) - 1; i >= 0; i-- {
+		unitTokens := core.CountTokens(units[i])
+		if tokens+unitTokens > limit {
+			break
+		}
+		tail = append([]string{units[i]}, tail...)
+		tokens += unitTokens
+	}
+
+	// Without a unit small enough, repeat the last words instead.
+	if len(tail) == 0 && len(units) > 0 {
+		words := strings.Fields(units[len(units)-1])
+		start := len(words)
+		for start > 0 && tokens+core.CountTokens(words[start-1]) <= limit {
+			start--
+			tokens += core.CountTokens(words[start])
+		}
+		if start < len(words) {
+			tail = []string{strings.Join(words[start:], " ")}
+		}
+	}
+	return tail
+}
+
+// mergeSmallChunks joins chunks below the minimum with the previous chunk of
+// the same section, or else the next one, as long as the result fits.
+func mergeSmallChunks(chunks []Chunk, employee string, limits ChunkLimits) []Chunk {
+	fits := func(a Chunk, b Chunk) bool {
+		header := core.CountTokens(chunkHeader(employee, a.Section))
+		return header+core.CountTokens(a.Text)+core.CountTokens(b.Text) <= limits.MaxTokens
+	}
+
+	var merged []Chunk
+	for i := 0; i < len(chunks); i++ {
+		chunk := chunks[i]
+		if core.CountTokens(chunk.Text) < limits.MinTokens {
+			if n := len(merged); n > 0 && merged[n-1].Section == chunk.Section && fits(merged[n-1], chunk) {
+				merged[n-1].Text += "\n\n" + chunk.Text
+				continue
+			}
+			if i+1 < len(chunks) && chunks[i+1].Section == chunk.Section && fits(chunk, chunks[i+1]) {
+				chunks[i+1].Text = chunk.Text + "\n\n" + chunks[i+1].Text
+				continue
+			}
+		}
+		merged = append(merged, chunk)
+	}
+	return merged
+}
@@ -6,13 +6,56 @@ DB_PWD="ChangeMe" # Don't add spaces and be careful with special symbols
 DB_SCHEMA="TF"
 DB_USER="user"
 DB_PORT="5432"
+DB_POOL_MAX_CONNS="20"
+DB_POOL_MIN_CONNS="2"
+DB_POOL_HEALTH_CHECK_PERIOD="30s"
+DB_POOL_ACQUIRE_TIMEOUT="5s" # How long a request waits for a free connection before failing
 
 # BE
 BE_HOST="teamforger.gchalakov.com"
 BE_PORT="8080"
+MIGRATE_ON_START="true" # Apply pending schema migrations when the backend starts
 ALLOWED_WS_ORIGIN="https://teamforger.gchalakov.com"
+LLM_PROVIDER="ollama" # "ollama", "openai" (any /v1/chat/completions server) or "fake"
 OLLAMA_API="http://192.168.0.27:11434/api/chat"
 OLLAMA_MODEL="gemma3:12b" #"gemma3:4b-it-qat" #"qwen3:4b" #"hf.co/Qwen/Qwen3-8B-GGUF:Q8_0"
 OLLAMA_CTX="4096"
-OLLAMA_EMB_API="http://192.168.0.27:11434/api/embeddings"
-OLLAMA_EMB_MODEL="nomic-embed-text"
+OPENAI_API="http://192.168.0.27:8000/v1/chat/completions"
+OPENAI_MODEL=""
+OPENAI_API_KEY=""
+EMB_PROVIDER="ollama" # "ollama", "openai" (any /v1/embeddings server) or "fake"
+EMB_BATCH_SIZE="16"
+OLLAMA_EMB_API="http://192.168.0.27:11434/api/embed"
+OLLAMA_EMB_MODEL="nomic-embed-text" # Must output 768 dimensions to fit cv_chunks.embedding
+OPENAI_EMB_API="http://192.168.0.27:8000/v1/embeddings"
+OPENAI_EMB_MODEL=""
+CV_WORKERS="2" # Background workers converting and embedding uploaded CVs
+CV_JOB_MAX_ATTEMPTS="3"
+CV_JOB_RETRY_DELAY="30s" # Doubles after every failed attempt
+CHUNK_MAX_TOKENS="400" # Keep well below the embedding model's context (2048 for nomic-embed-text in Ollama)
+CHUNK_OVERLAP_TOKENS="40"
+CHUNK_MIN_TOKENS="40" # Smaller chunks are merged with a neighbour of the same section
+CV_PROFILES_FILE="" # JSON file with CV section profiles, empty uses backend/app/pages/uploadCV/cv_profiles.json
+SKILL_EXTRACTION_TIMEOUT="2m" # Falls back to reading the skills section when the model is slower
+RETRIEVAL_VECTOR_WEIGHT="1" # Weight of the embedding search in the fused chunk ranking, 0 turns it off
+RETRIEVAL_KEYWORD_WEIGHT="1" # Weight of the full-text search, raise it to favour exact terms like "AZ-104"
+RETRIEVAL_RRF_K="60"
+RETRIEVAL_CANDIDATES="50" # Chunks ranked by each search before fusing
+RETRIEVAL_MODE="employees" # "employees" returns the best employees with their best chunks, "chunks" the best chunks overall
+RETRIEVAL_TOP_K="5" # Chunks, or employees in the employees mode, given to the model per question
+RETRIEVAL_CHUNKS_PER_EMPLOYEE="2"
+RETRIEVAL_MIN_SIMILARITY="0.4" # Chunks less similar to the question are never used, raise it if small talk still gets CV context
+RETRIEVAL_MMR_LAMBDA="1" # Below 1 trades relevance for diverse chunks, 1 turns diversification off
+RERANK_PROVIDER="none" # "none", "llm" (the chat model grades the chunks), "api" (a cross-encoder behind /v1/rerank) or "fake"
+RERANK_API="http://192.168.0.27:8000/v1/rerank"
+RERANK_MODEL=""
+RERANK_CANDIDATES="20" # Best chunks reranked per question
+RERANK_BUDGET="5s" # Slower reranks keep the retrieval order
+RERANK_CACHE_SIZE="10000" # Cached scores of question and chunk pairs
+QUERY_REWRITE="true" # Rewrite follow-up questions into standalone search queries
+QUERY_REWRITE_TIMEOUT="20s" # Search for the question as it is after this long
+QUERY_REWRITE_MESSAGES="6" # Earlier messages the rewrite sees
+CHAT_CONTEXT_TOKENS="4096" # Context window of the chat model, OLLAMA_CTX when unset
+CHAT_ANSWER_TOKENS="1024" # Kept free for the answer
+CHAT_SUMMARY_TOKENS="400" # Longest summary of old messages
+CHAT_CV_CONTEXT_SHARE="0.5" # Part of the window the CV excerpts may take, the history gets the rest
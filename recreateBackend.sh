@@ -14,11 +14,54 @@ docker run -d \
 	-e DB_PWD=$DB_PWD \
 	-e DB_SCHEMA=$DB_SCHEMA \
 	-e DB_CONTAINER_NAME=$DB_CONTAINER_NAME \
+	-e DB_POOL_MAX_CONNS=$DB_POOL_MAX_CONNS \
+	-e DB_POOL_MIN_CONNS=$DB_POOL_MIN_CONNS \
+	-e DB_POOL_HEALTH_CHECK_PERIOD=$DB_POOL_HEALTH_CHECK_PERIOD \
+	-e DB_POOL_ACQUIRE_TIMEOUT=$DB_POOL_ACQUIRE_TIMEOUT \
+	-e MIGRATE_ON_START=$MIGRATE_ON_START \
+	-e LLM_PROVIDER=$LLM_PROVIDER \
 	-e OLLAMA_API=$OLLAMA_API \
 	-e OLLAMA_MODEL=$OLLAMA_MODEL \
 	-e OLLAMA_CTX=$OLLAMA_CTX \
+	-e OPENAI_API=$OPENAI_API \
+	-e OPENAI_MODEL=$OPENAI_MODEL \
+	-e OPENAI_API_KEY=$OPENAI_API_KEY \
+	-e EMB_PROVIDER=$EMB_PROVIDER \
+	-e EMB_BATCH_SIZE=$EMB_BATCH_SIZE \
 	-e OLLAMA_EMB_API=$OLLAMA_EMB_API \
 	-e OLLAMA_EMB_MODEL=$OLLAMA_EMB_MODEL \
+	-e OPENAI_EMB_API=$OPENAI_EMB_API \
+	-e OPENAI_EMB_MODEL=$OPENAI_EMB_MODEL \
+	-e CV_WORKERS=$CV_WORKERS \
+	-e CV_JOB_MAX_ATTEMPTS=$CV_JOB_MAX_ATTEMPTS \
+	-e CV_JOB_RETRY_DELAY=$CV_JOB_RETRY_DELAY \
+	-e CHUNK_MAX_TOKENS=$CHUNK_MAX_TOKENS \
+	-e CHUNK_OVERLAP_TOKENS=$CHUNK_OVERLAP_TOKENS \
+	-e CHUNK_MIN_TOKENS=$CHUNK_MIN_TOKENS \
+	-e CV_PROFILES_FILE=$CV_PROFILES_FILE \
+	-e SKILL_EXTRACTION_TIMEOUT=$SKILL_EXTRACTION_TIMEOUT \
+	-e RETRIEVAL_VECTOR_WEIGHT=$RETRIEVAL_VECTOR_WEIGHT \
+	-e RETRIEVAL_KEYWORD_WEIGHT=$RETRIEVAL_KEYWORD_WEIGHT \
+	-e RETRIEVAL_RRF_K=$RETRIEVAL_RRF_K \
+	-e RETRIEVAL_CANDIDATES=$RETRIEVAL_CANDIDATES \
+	-e RETRIEVAL_MODE=$RETRIEVAL_MODE \
+	-e RETRIEVAL_TOP_K=$RETRIEVAL_TOP_K \
+	-e RETRIEVAL_CHUNKS_PER_EMPLOYEE=$RETRIEVAL_CHUNKS_PER_EMPLOYEE \
+	-e RETRIEVAL_MIN_SIMILARITY=$RETRIEVAL_MIN_SIMILARITY \
+	-e RETRIEVAL_MMR_LAMBDA=$RETRIEVAL_MMR_LAMBDA \
+	-e RERANK_PROVIDER=$RERANK_PROVIDER \
+	-e RERANK_API=$RERANK_API \
+	-e RERANK_MODEL=$RERANK_MODEL \
+	-e RERANK_CANDIDATES=$RERANK_CANDIDATES \
+	-e RERANK_BUDGET=$RERANK_BUDGET \
+	-e RERANK_CACHE_SIZE=$RERANK_CACHE_SIZE \
+	-e QUERY_REWRITE=$QUERY_REWRITE \
+	-e QUERY_REWRITE_TIMEOUT=$QUERY_REWRITE_TIMEOUT \
+	-e QUERY_REWRITE_MESSAGES=$QUERY_REWRITE_MESSAGES \
+	-e CHAT_CONTEXT_TOKENS=$CHAT_CONTEXT_TOKENS \
+	-e CHAT_ANSWER_TOKENS=$CHAT_ANSWER_TOKENS \
+	-e CHAT_SUMMARY_TOKENS=$CHAT_SUMMARY_TOKENS \
+	-e CHAT_CV_CONTEXT_SHARE=$CHAT_CV_CONTEXT_SHARE \
 	-e ALLOWED_WS_ORIGIN=$ALLOWED_WS_ORIGIN \
 	-e VIRTUAL_HOST=$BE_HOST \
 	-e LETSENCRYPT_HOST=$BE_HOST \